@@ -0,0 +1,367 @@
+package steam
+
+import (
+	"SteamSaleBot/lib/e"
+	"encoding/gob"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CacheConfig задает время жизни ответов по каждому запросу Steam.
+// Пока ответ старше TTL, но моложе TTL+Stale, кэш отдает его сразу и обновляет в фоне.
+type CacheConfig struct {
+	GameTTL  time.Duration
+	PriceTTL time.Duration
+	SaleTTL  time.Duration
+	Stale    time.Duration
+	Path     string // файл для сохранения кэша между перезапусками, пустой - только память
+}
+
+// Cache общий кэш поверх Client, реализует те же методы.
+type Cache struct {
+	client *Client
+	cfg    CacheConfig
+
+	mu         sync.Mutex
+	data       cacheData
+	refreshing map[string]bool
+	saving     bool       // запись на диск уже запланирована
+	saveMu     sync.Mutex // файл пишет только одна горутина
+}
+
+// saveDelay собирает изменения кэша за это время в одну запись файла.
+const saveDelay = 30 * time.Second
+
+type cacheData struct {
+	Games  map[string]gameEntry
+	Prices map[string]priceEntry
+	Sale   saleEntry
+}
+
+type gameEntry struct {
+	Value   GameData
+	Fetched time.Time
+}
+
+type priceEntry struct {
+	Value   GamePrice
+	Fetched time.Time
+}
+
+type saleEntry struct {
+	Value   []GameInfo
+	Fetched time.Time
+}
+
+type freshness int
+
+const (
+	missing freshness = iota
+	fresh
+	stale
+)
+
+func NewCache(client *Client, cfg CacheConfig) *Cache {
+	c := &Cache{
+		client: client,
+		cfg:    cfg,
+		data: cacheData{
+			Games:  make(map[string]gameEntry),
+			Prices: make(map[string]priceEntry),
+		},
+		refreshing: make(map[string]bool),
+	}
+	if cfg.Path != "" {
+		if err := c.load(); err != nil {
+			log.Println("can't load steam cache", err)
+		}
+	}
+	return c
+}
+
+func (c *Cache) Game(gameId string) (GameData, error) {
+	c.mu.Lock()
+	entry, ok := c.data.Games[gameId]
+	state := c.state(ok, entry.Fetched, c.cfg.GameTTL)
+	c.mu.Unlock()
+
+	switch state {
+	case fresh:
+		return entry.Value, nil
+	case stale:
+		c.refresh("game:"+gameId, func() error {
+			_, err := c.fetchGame(gameId)
+			return err
+		})
+		return entry.Value, nil
+	}
+	return c.fetchGame(gameId)
+}
+
+func (c *Cache) Prices(gameIDs []string) (map[string]GamePrice, error) {
+	res := make(map[string]GamePrice, len(gameIDs))
+	var toFetch, toRefresh []string
+
+	c.mu.Lock()
+	for _, id := range gameIDs {
+		entry, ok := c.data.Prices[id]
+		switch c.state(ok, entry.Fetched, c.cfg.PriceTTL) {
+		case fresh:
+			res[id] = entry.Value
+		case stale:
+			res[id] = entry.Value
+			// в фоне могут обновляться другие пачки, каждую цену обновляем одним запросом
+			if key := "price:" + id; !c.refreshing[key] {
+				c.refreshing[key] = true
+				toRefresh = append(toRefresh, id)
+			}
+		default:
+			toFetch = append(toFetch, id)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(toRefresh) > 0 {
+		go c.refreshPrices(toRefresh)
+	}
+	if len(toFetch) == 0 {
+		return res, nil
+	}
+
+	fetched, err := c.fetchPrices(toFetch)
+	if err != nil {
+		return nil, err
+	}
+	for id, price := range fetched {
+		res[id] = price
+	}
+	return res, nil
+}
+
+func (c *Cache) Sale() ([]GameInfo, error) {
+	c.mu.Lock()
+	entry := c.data.Sale
+	state := c.state(!entry.Fetched.IsZero(), entry.Fetched, c.cfg.SaleTTL)
+	c.mu.Unlock()
+
+	switch state {
+	case fresh:
+		return entry.Value, nil
+	case stale:
+		c.refresh("sale", func() error {
+			_, err := c.fetchSale()
+			return err
+		})
+		return entry.Value, nil
+	}
+	return c.fetchSale()
+}
+
+// Search не кэшируется: одинаковые запросы повторяются редко, а выдача быстро меняется.
+func (c *Cache) Search(term string) ([]SearchItem, error) {
+	return c.client.Search(term)
+}
+
+// Apps не кэшируется: для приложения запроса нет, а пакеты и наборы присылают редко.
+func (c *Cache) Apps(ref Ref) ([]string, error) {
+	return c.client.Apps(ref)
+}
+
+func (c *Cache) state(ok bool, fetched time.Time, ttl time.Duration) freshness {
+	if !ok {
+		return missing
+	}
+	age := time.Since(fetched)
+	switch {
+	case age < ttl:
+		return fresh
+	case age < ttl+c.cfg.Stale:
+		return stale
+	}
+	return missing
+}
+
+// refresh обновляет запись в фоне, не запуская повторное обновление того же ключа.
+func (c *Cache) refresh(key string, fetch func() error) {
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+		if err := fetch(); err != nil {
+			log.Printf("can't refresh steam cache %s: %v", key, err)
+		}
+	}()
+}
+
+// refreshPrices обновляет цены, уже отмеченные в refreshing ключами "price:<id>".
+func (c *Cache) refreshPrices(gameIDs []string) {
+	defer func() {
+		c.mu.Lock()
+		for _, id := range gameIDs {
+			delete(c.refreshing, "price:"+id)
+		}
+		c.mu.Unlock()
+	}()
+	if _, err := c.fetchPrices(gameIDs); err != nil {
+		log.Printf("can't refresh steam cache prices: %v", err)
+	}
+}
+
+func (c *Cache) fetchGame(gameId string) (GameData, error) {
+	data, err := c.client.Game(gameId)
+	if err != nil {
+		return data, err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	c.data.Games[gameId] = gameEntry{Value: data, Fetched: now}
+	c.data.Prices[gameId] = priceEntry{Value: data.Price, Fetched: now}
+	c.mu.Unlock()
+
+	c.save()
+	return data, nil
+}
+
+func (c *Cache) fetchPrices(gameIDs []string) (map[string]GamePrice, error) {
+	prices, err := c.client.Prices(gameIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	for id, price := range prices {
+		c.data.Prices[id] = priceEntry{Value: price, Fetched: now}
+	}
+	c.mu.Unlock()
+
+	c.save()
+	return prices, nil
+}
+
+func (c *Cache) fetchSale() ([]GameInfo, error) {
+	games, err := c.client.Sale()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.data.Sale = saleEntry{Value: games, Fetched: time.Now()}
+	c.mu.Unlock()
+
+	c.save()
+	return games, nil
+}
+
+// save планирует запись кэша на диск через saveDelay, изменения за это время попадут в ту же запись.
+func (c *Cache) save() {
+	if c.cfg.Path == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.saving {
+		return
+	}
+	c.saving = true
+	time.AfterFunc(saveDelay, c.flush)
+}
+
+func (c *Cache) flush() {
+	c.mu.Lock()
+	c.saving = false
+	c.mu.Unlock()
+
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+	if err := c.writeFile(); err != nil {
+		log.Println("can't save steam cache", err)
+	}
+}
+
+// evict удаляет записи, которые уже нельзя отдать даже как устаревшие, вызывается под mu.
+func (c *Cache) evict() {
+	for id, entry := range c.data.Games {
+		if c.state(true, entry.Fetched, c.cfg.GameTTL) == missing {
+			delete(c.data.Games, id)
+		}
+	}
+	for id, entry := range c.data.Prices {
+		if c.state(true, entry.Fetched, c.cfg.PriceTTL) == missing {
+			delete(c.data.Prices, id)
+		}
+	}
+	if c.state(true, c.data.Sale.Fetched, c.cfg.SaleTTL) == missing {
+		c.data.Sale = saleEntry{}
+	}
+}
+
+func (c *Cache) writeFile() (err error) {
+	defer func() { err = e.WrapIfErr("can't write cache", err) }()
+
+	if err := os.MkdirAll(filepath.Dir(c.cfg.Path), 0774); err != nil {
+		return err
+	}
+
+	// пишем во временный файл и переименовываем, чтобы не оставить обрезанный кэш
+	tmp := c.cfg.Path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.evict()
+	err = gob.NewEncoder(file).Encode(c.data)
+	c.mu.Unlock()
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, c.cfg.Path)
+}
+
+func (c *Cache) load() (err error) {
+	defer func() { err = e.WrapIfErr("can't read cache", err) }()
+
+	file, err := os.Open(c.cfg.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	var data cacheData
+	if err := gob.NewDecoder(file).Decode(&data); err != nil {
+		return err
+	}
+	if data.Games != nil {
+		c.data.Games = data.Games
+	}
+	if data.Prices != nil {
+		c.data.Prices = data.Prices
+	}
+	c.data.Sale = data.Sale
+	c.evict()
+	return nil
+}
@@ -0,0 +1,80 @@
+package steam
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheRefreshesEveryStaleBatch(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		requested = make(map[string]int)
+		block     = make(chan struct{})
+		blocking  bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := r.URL.Query().Get("appids")
+		mu.Lock()
+		requested[ids]++
+		wait := blocking && ids == "1"
+		mu.Unlock()
+		if wait {
+			<-block
+		}
+
+		var parts []string
+		for _, id := range strings.Split(ids, ",") {
+			parts = append(parts, `"`+id+`":{"success":true,"data":{"price_overview":{"initial":100,"final":100,"currency":"RUB"}}}`)
+		}
+		_, _ = w.Write([]byte("{" + strings.Join(parts, ",") + "}"))
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	c := NewCache(New(srv.URL, 6000), CacheConfig{PriceTTL: 10 * time.Millisecond, Stale: time.Hour})
+	for _, id := range []string{"1", "2"} {
+		if _, err := c.Prices([]string{id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// пока первая пачка обновляется, вторая тоже должна уйти в Steam
+	mu.Lock()
+	blocking = true
+	mu.Unlock()
+	for _, id := range []string{"1", "2"} {
+		if _, err := c.Prices([]string{id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		got1, got2 := requested["1"], requested["2"]
+		mu.Unlock()
+		if got1 == 2 && got2 == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("requests for 1: %d, for 2: %d, want 2 each", got1, got2)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// повторный запрос той же цены во время обновления не дублирует его
+	if _, err := c.Prices([]string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if requested["1"] != 2 {
+		t.Fatalf("price 1 requested %d times, want 2", requested["1"])
+	}
+}
@@ -0,0 +1,70 @@
+package steam
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrRateLimited  = errors.New("steam: too many requests")
+	ErrForbidden    = errors.New("steam: access forbidden")
+	ErrServer       = errors.New("steam: server error")
+	ErrGameNotFound = errors.New("steam: game not found")
+)
+
+// StatusError неуспешный HTTP ответ Steam, оборачивает один из Err* выше.
+type StatusError struct {
+	Code       int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v (status %d)", e.Err, e.Code)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnavailable сообщает, что Steam временно не отвечает и запрос стоит повторить позже.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrForbidden) || errors.Is(err, ErrServer)
+}
+
+func statusError(resp *http.Response) error {
+	var err error
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		err = ErrRateLimited
+	case resp.StatusCode == http.StatusForbidden:
+		err = ErrForbidden
+	case resp.StatusCode >= 500:
+		err = ErrServer
+	default:
+		err = fmt.Errorf("steam: unexpected status %s", resp.Status)
+	}
+	return &StatusError{
+		Code:       resp.StatusCode,
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		Err:        err,
+	}
+}
+
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
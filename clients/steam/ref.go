@@ -0,0 +1,101 @@
+package steam
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var ErrBadRef = errors.New("steam: not an app id or store link")
+
+type RefKind int
+
+const (
+	AppRef RefKind = iota
+	SubRef
+	BundleRef
+)
+
+// Ref то, на что указывает ссылка пользователя: приложение, пакет (sub) или набор (bundle).
+type Ref struct {
+	Kind RefKind
+	ID   string
+}
+
+// refHosts сайты, в ссылках которых ищем /app/<id>, /sub/<id> и /bundle/<id>.
+var refHosts = map[string]bool{
+	"store.steampowered.com": true,
+	"steamcommunity.com":     true,
+	"steamdb.info":           true,
+}
+
+// ParseRef понимает id приложения, ссылки магазина и сообщества Steam, ссылки SteamDB
+// и steam:// ссылки клиента, например steam://store/312520 или steam://openurl/<ссылка магазина>.
+func ParseRef(s string) (Ref, error) {
+	s = strings.Trim(strings.TrimSpace(s), "<>")
+	if id, ok := parseID(s); ok {
+		return Ref{Kind: AppRef, ID: id}, nil
+	}
+
+	if rest, ok := cutPrefixFold(s, "steam://"); ok {
+		return parseClientRef(rest)
+	}
+	if !strings.Contains(s, "://") {
+		// ссылку часто копируют без схемы: store.steampowered.com/app/312520
+		s = "https://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || !refHosts[strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")] {
+		return Ref{}, ErrBadRef
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		id, ok := parseID(parts[i+1])
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(parts[i]) {
+		case "app":
+			return Ref{Kind: AppRef, ID: id}, nil
+		case "sub":
+			return Ref{Kind: SubRef, ID: id}, nil
+		case "bundle":
+			return Ref{Kind: BundleRef, ID: id}, nil
+		}
+	}
+	return Ref{}, ErrBadRef
+}
+
+// parseClientRef разбирает steam:// ссылку без схемы: "store/312520", "run/312520", "openurl/https://...".
+func parseClientRef(rest string) (Ref, error) {
+	cmd, arg, _ := strings.Cut(rest, "/")
+	if strings.EqualFold(cmd, "openurl") {
+		return ParseRef(arg)
+	}
+
+	parts := strings.Split(strings.Trim(arg, "/"), "/")
+	if id, ok := parseID(parts[len(parts)-1]); ok {
+		return Ref{Kind: AppRef, ID: id}, nil
+	}
+	return Ref{}, ErrBadRef
+}
+
+// parseID проверяет, что s положительное число, и приводит его к виду без ведущих нулей:
+// от id зависят имя файла игры и ключ в ответе Steam.
+func parseID(s string) (string, bool) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || strings.HasPrefix(s, "+") {
+		return "", false
+	}
+	return strconv.Itoa(n), true
+}
+
+func cutPrefixFold(s string, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
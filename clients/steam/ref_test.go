@@ -0,0 +1,59 @@
+package steam
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Ref
+		wantErr bool
+	}{
+		{"312520", Ref{AppRef, "312520"}, false},
+		{" 312520 ", Ref{AppRef, "312520"}, false},
+		{"0312520", Ref{AppRef, "312520"}, false},
+		{"https://store.steampowered.com/app/312520/Rain_World/", Ref{AppRef, "312520"}, false},
+		{"http://store.steampowered.com/app/312520", Ref{AppRef, "312520"}, false},
+		{"store.steampowered.com/app/312520/Rain_World/", Ref{AppRef, "312520"}, false},
+		{"<https://www.store.steampowered.com/app/312520>", Ref{AppRef, "312520"}, false},
+		{"https://STORE.steampowered.com/App/312520", Ref{AppRef, "312520"}, false},
+		{"https://store.steampowered.com/agecheck/app/312520/", Ref{AppRef, "312520"}, false},
+		{"https://store.steampowered.com/sub/469/", Ref{SubRef, "469"}, false},
+		{"https://store.steampowered.com/bundle/232/Valve_Complete_Pack/", Ref{BundleRef, "232"}, false},
+		{"https://steamcommunity.com/app/312520/reviews/", Ref{AppRef, "312520"}, false},
+		{"https://steamdb.info/app/312520/", Ref{AppRef, "312520"}, false},
+		{"steamdb.info/sub/469/", Ref{SubRef, "469"}, false},
+		{"https://steamdb.info/bundle/232/", Ref{BundleRef, "232"}, false},
+		{"steam://store/312520", Ref{AppRef, "312520"}, false},
+		{"steam://run/312520", Ref{AppRef, "312520"}, false},
+		{"STEAM://store/312520/", Ref{AppRef, "312520"}, false},
+		{"steam://openurl/https://store.steampowered.com/app/312520", Ref{AppRef, "312520"}, false},
+		{"steam://openurl/https://store.steampowered.com/sub/469", Ref{SubRef, "469"}, false},
+
+		{"", Ref{}, true},
+		{"Rain World", Ref{}, true},
+		{"0", Ref{}, true},
+		{"-312520", Ref{}, true},
+		{"+312520", Ref{}, true},
+		{"1000xRESIST", Ref{}, true},
+		{"https://example.com/app/312520", Ref{}, true},
+		{"https://store.steampowered.com/search/?term=rain", Ref{}, true},
+		{"https://store.steampowered.com/app/abc", Ref{}, true},
+		{"steam://store/", Ref{}, true},
+		{"steam://openurl/https://example.com/app/312520", Ref{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRef(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrBadRef) {
+				t.Errorf("ParseRef(%q) = %+v, %v, want ErrBadRef", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRef(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
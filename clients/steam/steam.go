@@ -0,0 +1,336 @@
+package steam
+
+import (
+	"SteamSaleBot/lib/e"
+	"SteamSaleBot/lib/limiter"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	baseURL string
+	client  http.Client
+	limiter *limiter.Limiter
+}
+
+const (
+	appDetailsPath  = "api/appdetails"
+	searchPath      = "search/"
+	storeSearchPath = "api/storesearch/"
+	packagePath     = "api/packagedetails"
+	bundlePath      = "actions/ajaxresolvebundles"
+
+	maxRetries  = 3
+	backoffBase = 2 * time.Second
+	limitBurst  = 5
+)
+
+// New создает клиент магазина Steam, baseURL обычно https://store.steampowered.com,
+// но его можно направить на локальный сервер. Все запросы клиента делят лимит perMinute.
+func New(baseURL string, perMinute int) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  http.Client{},
+		limiter: limiter.New(float64(perMinute)/60, limitBurst),
+	}
+}
+
+func (c *Client) Game(gameId string) (g GameData, err error) {
+	q := url.Values{}
+	q.Add("appids", gameId)
+	q.Add("cc", "ru")
+	q.Add("l", "ru")
+
+	body, err := c.doRequest(appDetailsPath, q)
+	if err != nil {
+		return g, e.Warp("can't import game", err)
+	}
+	var result map[string]GameResponse
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return g, err
+	}
+
+	gameResp, ok := result[gameId]
+	if !ok || !gameResp.Success {
+		return GameData{}, ErrGameNotFound
+	}
+	fillFormatted(&gameResp.Data.Price)
+	return gameResp.Data, nil
+}
+
+// Prices получает только цены сразу для нескольких игр,
+// Steam отдает несколько appids за раз лишь с filters=price_overview.
+// Игры, по которым Steam не ответил успешно, в результат не попадают.
+func (c *Client) Prices(gameIDs []string) (res map[string]GamePrice, err error) {
+	defer func() { err = e.WrapIfErr("can't get prices", err) }()
+
+	q := url.Values{}
+	q.Add("appids", strings.Join(gameIDs, ","))
+	q.Add("filters", "price_overview")
+	q.Add("cc", "ru")
+	q.Add("l", "ru")
+
+	body, err := c.doRequest(appDetailsPath, q)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]priceResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	res = make(map[string]GamePrice, len(result))
+	for id, r := range result {
+		if !r.Success {
+			continue
+		}
+		var data struct {
+			Price GamePrice `json:"price_overview"`
+		}
+		// у бесплатных игр вместо объекта приходит пустой массив
+		if len(r.Data) > 0 && r.Data[0] == '{' {
+			if err := json.Unmarshal(r.Data, &data); err != nil {
+				return nil, err
+			}
+		}
+		fillFormatted(&data.Price)
+		res[id] = data.Price
+	}
+	return res, nil
+}
+
+// Search ищет игры по названию, Steam сам сортирует результаты по релевантности.
+func (c *Client) Search(term string) (items []SearchItem, err error) {
+	defer func() { err = e.WrapIfErr("can't search games", err) }()
+
+	q := url.Values{}
+	q.Add("term", term)
+	q.Add("cc", "ru")
+	q.Add("l", "russian")
+
+	body, err := c.doRequest(storeSearchPath, q)
+	if err != nil {
+		return nil, err
+	}
+	var result searchResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	for _, item := range result.Items {
+		// кроме игр поиск находит пакеты и наборы, их нельзя отслеживать через appdetails
+		if item.Type == "app" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// Apps приводит ссылку к id приложений: пакет (sub) и набор (bundle) раскрываются в игры,
+// которые в них входят, потому что цены отслеживаются только у приложений.
+func (c *Client) Apps(ref Ref) (ids []string, err error) {
+	defer func() { err = e.WrapIfErr("can't resolve apps", err) }()
+
+	switch ref.Kind {
+	case AppRef:
+		return []string{ref.ID}, nil
+	case SubRef:
+		ids, err = c.packageApps(ref.ID)
+	case BundleRef:
+		ids, err = c.bundleApps(ref.ID)
+	default:
+		return nil, ErrBadRef
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrGameNotFound
+	}
+	return ids, nil
+}
+
+func (c *Client) packageApps(subID string) ([]string, error) {
+	q := url.Values{}
+	q.Add("packageids", subID)
+	q.Add("cc", "ru")
+	q.Add("l", "ru")
+
+	body, err := c.doRequest(packagePath, q)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]packageResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	pkg, ok := result[subID]
+	if !ok || !pkg.Success {
+		return nil, ErrGameNotFound
+	}
+	ids := make([]string, 0, len(pkg.Data.Apps))
+	for _, app := range pkg.Data.Apps {
+		ids = append(ids, strconv.Itoa(app.ID))
+	}
+	return ids, nil
+}
+
+func (c *Client) bundleApps(bundleID string) ([]string, error) {
+	q := url.Values{}
+	q.Add("bundleids", bundleID)
+	q.Add("cc", "RU")
+	q.Add("l", "russian")
+
+	body, err := c.doRequest(bundlePath, q)
+	if err != nil {
+		return nil, err
+	}
+	// на неизвестный набор Steam отвечает пустым массивом
+	var result []bundleResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, b := range result {
+		if strconv.Itoa(b.BundleID) != bundleID {
+			continue
+		}
+		for _, id := range b.AppIDs {
+			ids = append(ids, strconv.Itoa(id))
+		}
+	}
+	return ids, nil
+}
+
+func fillFormatted(p *GamePrice) {
+	if p.FinalFormatted == "" {
+		p.FinalFormatted = "бесплатно"
+	}
+	if p.InitialFormatted == "" {
+		p.InitialFormatted = p.FinalFormatted
+	}
+}
+
+func (c *Client) Sale() (g []GameInfo, err error) {
+	q := url.Values{}
+	q.Add("filter", "weeklongdeals")
+
+	body, err := c.doRequest(searchPath, q)
+	if err != nil {
+		return g, e.Warp("can't import game", err)
+	}
+	games, err := c.parseGamesSale(body)
+	if err != nil {
+		return g, e.Warp("can't parse sale", err)
+	}
+	return games, nil
+}
+
+// doRequest ждет лимит и повторяет запрос с экспоненциальной задержкой, если Steam ответил 429 или 5xx.
+func (c *Client) doRequest(path string, query url.Values) (data []byte, err error) {
+	defer func() { err = e.WrapIfErr("can't do request", err) }()
+
+	for attempt := 0; ; attempt++ {
+		c.limiter.Wait()
+
+		data, err = c.do(path, query)
+		if err == nil {
+			return data, nil
+		}
+
+		var se *StatusError
+		if !errors.As(err, &se) || errors.Is(err, ErrForbidden) || attempt >= maxRetries {
+			return nil, err
+		}
+
+		delay := backoff(attempt)
+		if se.RetryAfter > delay {
+			delay = se.RetryAfter
+		}
+		if errors.Is(err, ErrRateLimited) {
+			c.limiter.Pause(delay)
+		}
+		log.Printf("%v, retry %d/%d in %v", err, attempt+1, maxRetries, delay)
+		time.Sleep(delay)
+	}
+}
+
+func (c *Client) do(path string, query url.Values) (data []byte, err error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/"+path, nil)
+	if err != nil {
+		return data, err
+	}
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("X-Forwarded-For", "213.180.204.3")
+	req.Header.Add("Accept-Language", "ru")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return data, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err := statusError(resp); err != nil {
+		return data, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return data, err
+	}
+	return body, nil
+}
+
+// backoff 2s, 4s, 8s... плюс случайная добавка до половины задержки.
+func backoff(attempt int) time.Duration {
+	d := backoffBase << attempt
+	return d + rand.N(d/2)
+}
+
+func (c *Client) parseGamesSale(body []byte) ([]GameInfo, error) {
+	var games []GameInfo
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Find(".search_result_row").Each(func(i int, s *goquery.Selection) {
+		title := strings.TrimSpace(s.Find(".title").Text())
+		href, _ := s.Attr("href")
+
+		// Цены
+		finalPrice := strings.TrimSpace(s.Find(".discount_final_price").Text())
+		oldPrice := strings.TrimSpace(s.Find(".discount_original_price").Text())
+
+		if finalPrice == "" {
+			// Если нет скидки, пробуем просто .search_price
+			raw := strings.TrimSpace(s.Find(".search_price").Text())
+			finalPrice = strings.Join(strings.Fields(raw), " ")
+		}
+
+		game := GameInfo{
+			Title:      title,
+			OldPrice:   oldPrice,
+			FinalPrice: finalPrice,
+			URL:        href,
+		}
+
+		games = append(games, game)
+	})
+
+	return games, nil
+}
@@ -0,0 +1,53 @@
+package steam
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestApps(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.URL.Path == "/"+packagePath && q.Get("packageids") == "469":
+			_, _ = w.Write([]byte(`{"469":{"success":true,"data":{"name":"The Orange Box","apps":[{"id":400,"name":"Portal"},{"id":420,"name":"Half-Life 2: Episode Two"}]}}}`))
+		case r.URL.Path == "/"+packagePath:
+			_, _ = w.Write([]byte(`{"` + q.Get("packageids") + `":{"success":false}}`))
+		case r.URL.Path == "/"+bundlePath && q.Get("bundleids") == "232":
+			_, _ = w.Write([]byte(`[{"bundleid":232,"name":"Valve Complete Pack","appids":[10,20,400]}]`))
+		case r.URL.Path == "/"+bundlePath:
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL, 6000)
+
+	tests := []struct {
+		name    string
+		ref     Ref
+		want    []string
+		wantErr error
+	}{
+		{"app", Ref{Kind: AppRef, ID: "312520"}, []string{"312520"}, nil},
+		{"sub", Ref{Kind: SubRef, ID: "469"}, []string{"400", "420"}, nil},
+		{"bundle", Ref{Kind: BundleRef, ID: "232"}, []string{"10", "20", "400"}, nil},
+		{"unknown sub", Ref{Kind: SubRef, ID: "1"}, nil, ErrGameNotFound},
+		{"unknown bundle", Ref{Kind: BundleRef, ID: "1"}, nil, ErrGameNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Apps(tt.ref)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apps(%+v) error = %v, want %v", tt.ref, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apps(%+v) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package steam
+
+import "encoding/json"
+
+type GameResponse struct {
+	Success bool     `json:"success"`
+	Data    GameData `json:"data"`
+}
+
+type priceResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type searchResponse struct {
+	Total int          `json:"total"`
+	Items []SearchItem `json:"items"`
+}
+
+type packageResponse struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Apps []struct {
+			ID int `json:"id"`
+		} `json:"apps"`
+	} `json:"data"`
+}
+
+type bundleResponse struct {
+	BundleID int   `json:"bundleid"`
+	AppIDs   []int `json:"appids"`
+}
+
+type SearchItem struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type GameData struct {
+	Name        string    `json:"name"`
+	IsFree      bool      `json:"is_free"`
+	Description string    `json:"short_description"`
+	Languages   string    `json:"supported_languages"`
+	Price       GamePrice `json:"price_overview"`
+}
+
+type GameInfo struct {
+	Title      string
+	OldPrice   string
+	FinalPrice string
+	URL        string
+}
+
+type GamePrice struct {
+	Initial          int    `json:"initial"` // в копейках
+	Final            int    `json:"final"`
+	DiscountPercent  int    `json:"discount_percent"`
+	Currency         string `json:"currency"`
+	InitialFormatted string `json:"initial_formatted"`
+	FinalFormatted   string `json:"final_formatted"`
+}
@@ -0,0 +1,130 @@
+package telegram
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MaxMessageLength ограничение Telegram на длину текста, считается в UTF-16 символах.
+const MaxMessageLength = 4096
+
+// Chunks собирает из заголовка и записей списка сообщения не длиннее limit.
+// Записи не разрываются между сообщениями, а запись длиннее limit режется по строкам
+// или пробелам так, чтобы не разорвать разметку MarkdownV2.
+func Chunks(header string, entries []string, limit int) []string {
+	var res []string
+	cur := header
+
+	for _, entry := range entries {
+		if textLen(cur)+textLen(entry) <= limit {
+			cur += entry
+			continue
+		}
+		if strings.TrimSpace(cur) != "" {
+			res = append(res, cur)
+		}
+
+		for textLen(entry) > limit {
+			i := cutIndex(entry, limit)
+			res = append(res, entry[:i])
+			entry = entry[i:]
+		}
+		cur = entry
+	}
+
+	if strings.TrimSpace(cur) != "" {
+		res = append(res, cur)
+	}
+	return res
+}
+
+// cutIndex ищет байтовую позицию не дальше limit символов, где не открыта ни одна сущность MarkdownV2:
+// сначала после перевода строки, потом после пробела. Если такой нет, режет по limit.
+func cutIndex(s string, limit int) int {
+	var (
+		open      []string // открытые *, _, __, ~, || и [ в порядке вложенности
+		code      bool
+		pre       bool
+		url       bool
+		escaped   bool
+		skip      int // сколько символов уже разобранного маркера из нескольких символов пропустить
+		length    = 0
+		lastLine  = 0
+		lastSpace = 0
+	)
+	toggle := func(marker string) {
+		if n := len(open); n > 0 && open[n-1] == marker {
+			open = open[:n-1]
+			return
+		}
+		open = append(open, marker)
+	}
+
+	for i, r := range s {
+		length += utf16.RuneLen(r)
+		if length > limit {
+			switch {
+			case lastLine > 0:
+				return lastLine
+			case lastSpace > 0:
+				return lastSpace
+			}
+			return i
+		}
+
+		rest := s[i:]
+		next := i + utf8.RuneLen(r)
+		switch {
+		case skip > 0:
+			skip--
+		case escaped:
+			// экранированный символ ничего не открывает и не закрывает ни в одной сущности
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case pre:
+			if strings.HasPrefix(rest, "```") {
+				pre = false
+				skip = 2
+			}
+		case code:
+			code = r != '`'
+		case url:
+			url = r != ')'
+		case strings.HasPrefix(rest, "```"):
+			pre = true
+			skip = 2
+		case r == '`':
+			code = true
+		case r == '[':
+			open = append(open, "[")
+		case r == ']':
+			if n := len(open); n > 0 && open[n-1] == "[" {
+				open = open[:n-1]
+			}
+			if strings.HasPrefix(s[next:], "(") {
+				url = true
+				skip = 1
+			}
+		case strings.HasPrefix(rest, "__"), strings.HasPrefix(rest, "||"):
+			toggle(rest[:2])
+			skip = 1
+		case r == '*' || r == '_' || r == '~':
+			toggle(string(r))
+		case len(open) == 0 && r == '\n':
+			lastLine = next
+		case len(open) == 0 && r == ' ':
+			lastSpace = next
+		}
+	}
+	return len(s)
+}
+
+func textLen(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
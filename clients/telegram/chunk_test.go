@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"SteamSaleBot/lib/format"
+	"reflect"
+	"testing"
+)
+
+func TestCutIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		limit int
+		want  int
+	}{
+		{"space", "aaa bbb ccc", 6, 4},
+		{"newline before space", "line1\nline2 more", 14, 6},
+		{"no cut point", "abcdefgh", 5, 5},
+		{"utf16 length", "😀😀 ab", 4, 8},
+		{"escaped stars in bold", `*a \*b\** tail end`, 14, 10},
+		{"escaped paren in link url", `[x](http://a/\)_b) tail end`, 23, 19},
+		{"escaped backtick in code", "`a \\` b` c d", 10, 9},
+		{"spoiler", "||sec ret|| tail end", 16, 12},
+		{"underline", "__u v__ tail end", 12, 8},
+		{"strike", "~s t~ tail end", 10, 6},
+		{"nested", "*b _i j_ k* tail end", 16, 12},
+		{"pre", "```\na b\n``` tail end", 16, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cutIndex(tt.s, tt.limit); got != tt.want {
+				t.Errorf("cutIndex(%q, %d) = %d, want %d", tt.s, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunks(t *testing.T) {
+	escaped := format.New(format.MarkdownV2).Bold("a *b*").Text(" tail end").String()
+	link := format.New(format.MarkdownV2).Link("Открыть", "http://a/(b)_c").Text(" tail end").String()
+
+	tests := []struct {
+		name    string
+		header  string
+		entries []string
+		limit   int
+		want    []string
+	}{
+		{"fits", "H\n", []string{"a\n", "b\n"}, 100, []string{"H\na\nb\n"}},
+		{"split by entries", "", []string{"abc\n", "def\n"}, 5, []string{"abc\n", "def\n"}},
+		{"blank header skipped", "\n", []string{"abcd\n"}, 5, []string{"abcd\n"}},
+		{"long entry", "", []string{"*bold text* and more words"}, 12, []string{"*bold text* ", "and more ", "words"}},
+		{"escaped name", "", []string{escaped}, 14, []string{`*a \*b\** `, "tail end"}},
+		{"escaped link", "", []string{link}, len([]rune(link)) - 3, []string{`[Открыть](http://a/(b\)_c) tail `, "end"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Chunks(tt.header, tt.entries, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Chunks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBotBlocked   = errors.New("telegram: bot was blocked by the user")
+	ErrChatNotFound = errors.New("telegram: chat not found")
+	ErrBadMarkup    = errors.New("telegram: can't parse message entities")
+	ErrFlood        = errors.New("telegram: too many requests")
+	ErrNotModified  = errors.New("telegram: message is not modified")
+)
+
+// APIError ответ Telegram с ok=false, через errors.Is сравнивается с Err* выше.
+type APIError struct {
+	Code        int
+	Description string
+	RetryAfter  time.Duration
+	Err         error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram: %d %s", e.Code, e.Description)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+func newAPIError(res Response) *APIError {
+	apiErr := &APIError{Code: res.ErrorCode, Description: res.Description}
+	if res.Parameters != nil {
+		apiErr.RetryAfter = time.Duration(res.Parameters.RetryAfter) * time.Second
+	}
+
+	desc := strings.ToLower(res.Description)
+	switch {
+	case res.ErrorCode == http.StatusForbidden:
+		// blocked by the user, user is deactivated, kicked from the group chat
+		apiErr.Err = ErrBotBlocked
+	case res.ErrorCode == http.StatusTooManyRequests:
+		apiErr.Err = ErrFlood
+	case strings.Contains(desc, "chat not found"):
+		apiErr.Err = ErrChatNotFound
+	case strings.Contains(desc, "can't parse entities"):
+		apiErr.Err = ErrBadMarkup
+	case strings.Contains(desc, "message is not modified"):
+		// повторное нажатие той же кнопки, текст и клавиатура не изменились
+		apiErr.Err = ErrNotModified
+	}
+	return apiErr
+}
@@ -0,0 +1,30 @@
+package telegram
+
+// InlineKeyboard разметка кнопок под сообщением, передается в reply_markup.
+type InlineKeyboard struct {
+	Rows [][]InlineButton `json:"inline_keyboard"`
+}
+
+// InlineButton кнопка с CallbackData приходит боту как callback_query, с URL открывает ссылку.
+// Telegram ограничивает CallbackData 64 байтами.
+type InlineButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data,omitempty"`
+	URL          string `json:"url,omitempty"`
+}
+
+func NewKeyboard(rows ...[]InlineButton) *InlineKeyboard {
+	return &InlineKeyboard{Rows: rows}
+}
+
+func Row(buttons ...InlineButton) []InlineButton {
+	return buttons
+}
+
+func CallbackButton(text string, data string) InlineButton {
+	return InlineButton{Text: text, CallbackData: data}
+}
+
+func URLButton(text string, url string) InlineButton {
+	return InlineButton{Text: text, URL: url}
+}
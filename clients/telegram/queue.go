@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"SteamSaleBot/lib/limiter"
+	"sync"
+	"time"
+)
+
+// Ограничения Telegram: около 30 сообщений в секунду на бота и 1 в секунду в один чат.
+const (
+	globalPerSecond = 30
+	chatInterval    = time.Second
+	maxSendRetries  = 3
+	chatsPruneSize  = 1000
+)
+
+// sendQueue выстраивает отправку сообщений в очередь по общему и по чатовому лимиту.
+type sendQueue struct {
+	global *limiter.Limiter
+
+	mu    sync.Mutex
+	chats map[int]time.Time // когда в чат можно отправить следующее сообщение
+}
+
+func newSendQueue() *sendQueue {
+	return &sendQueue{
+		global: limiter.New(globalPerSecond, globalPerSecond),
+		chats:  make(map[int]time.Time),
+	}
+}
+
+// wait занимает следующий слот чата и ждет его, затем ждет общий лимит.
+func (q *sendQueue) wait(chatID int) {
+	q.mu.Lock()
+	now := time.Now()
+	next := q.chats[chatID]
+	if next.Before(now) {
+		next = now
+	}
+	q.chats[chatID] = next.Add(chatInterval)
+	if len(q.chats) > chatsPruneSize {
+		q.prune(now)
+	}
+	q.mu.Unlock()
+
+	time.Sleep(time.Until(next))
+	q.global.Wait()
+}
+
+// pause откладывает отправку на d, Telegram вернул retry_after. Ограничение действует на весь бот,
+// поэтому останавливается и общий лимит, а чат, получивший 429, не обгонит остальных после паузы.
+func (q *sendQueue) pause(chatID int, d time.Duration) {
+	q.global.Pause(d)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(q.chats[chatID]) {
+		q.chats[chatID] = until
+	}
+}
+
+func (q *sendQueue) prune(now time.Time) {
+	for id, next := range q.chats {
+		if next.Before(now) {
+			delete(q.chats, id)
+		}
+	}
+}
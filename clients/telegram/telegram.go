@@ -2,34 +2,50 @@ package telegram
 
 import (
 	"SteamSaleBot/lib/e"
-	"bytes"
+	"SteamSaleBot/lib/format"
 	"encoding/json"
-	"fmt"
-	"github.com/PuerkitoBio/goquery"
+	"errors"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
-	"strings"
+	"time"
 )
 
 type Client struct {
 	host     string
 	basePath string
 	client   http.Client
+	queue    *sendQueue
 }
 
 const (
-	getUpdatesMethod  = "getUpdates"
-	sendMessageMethod = "sendMessage"
+	getMeMethod               = "getMe"
+	getUpdatesMethod          = "getUpdates"
+	sendMessageMethod         = "sendMessage"
+	answerCallbackQueryMethod = "answerCallbackQuery"
+	editMessageTextMethod     = "editMessageText"
+	setWebhookMethod          = "setWebhook"
+	deleteWebhookMethod       = "deleteWebhook"
 )
 
+// pollTimeout сколько секунд Telegram держит getUpdates, если новых обновлений нет.
+const pollTimeout = 30
+
+// SecretTokenHeader заголовок, в котором Telegram присылает secret_token вебхука.
+const SecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// allowedUpdates типы обновлений, которые обрабатывает бот.
+const allowedUpdates = `["message","callback_query"]`
+
 func New(host string, token string) *Client {
 	return &Client{
 		host:     host,
 		basePath: newBasePath(token),
 		client:   http.Client{},
+		queue:    newSendQueue(),
 	}
 }
 
@@ -37,12 +53,30 @@ func newBasePath(token string) string {
 	return "bot" + token
 }
 
+// Me возвращает аккаунт самого бота.
+func (c *Client) Me() (me From, err error) {
+	defer func() { err = e.WrapIfErr("can't get me", err) }()
+
+	data, err := c.doTgRequest(getMeMethod, url.Values{})
+	if err != nil {
+		return me, err
+	}
+
+	var res MeResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return me, err
+	}
+	return res.Result, nil
+}
+
 func (c *Client) Updates(offset int, limit int) (updates []Update, err error) {
 	defer func() { err = e.WrapIfErr("can't get updates", err) }()
 
 	q := url.Values{}
 	q.Add("offset", strconv.Itoa(offset))
 	q.Add("limit", strconv.Itoa(limit))
+	q.Add("timeout", strconv.Itoa(pollTimeout))
+	q.Add("allowed_updates", allowedUpdates)
 
 	data, err := c.doTgRequest(getUpdatesMethod, q)
 	if err != nil {
@@ -58,76 +92,133 @@ func (c *Client) Updates(offset int, limit int) (updates []Update, err error) {
 	return res.Result, nil
 }
 
+// SetWebhook включает доставку обновлений на url, Telegram передает secret в заголовке SecretTokenHeader.
+// Пока вебхук включен, getUpdates не работает.
+func (c *Client) SetWebhook(webhookURL string, secret string) (err error) {
+	defer func() { err = e.WrapIfErr("can't set webhook", err) }()
+
+	q := url.Values{}
+	q.Add("url", webhookURL)
+	q.Add("allowed_updates", allowedUpdates)
+	if secret != "" {
+		q.Add("secret_token", secret)
+	}
+
+	_, err = c.doTgRequest(setWebhookMethod, q)
+	return err
+}
+
+// DeleteWebhook выключает вебхук, чтобы снова получать обновления через getUpdates.
+func (c *Client) DeleteWebhook() (err error) {
+	defer func() { err = e.WrapIfErr("can't delete webhook", err) }()
+
+	_, err = c.doTgRequest(deleteWebhookMethod, url.Values{})
+	return err
+}
+
+// SendMessage отправляет текст в старом Markdown, подходит для заранее написанных сообщений.
 func (c *Client) SendMessage(chatID int, text string) error {
+	return c.SendFormatted(chatID, text, "Markdown")
+}
+
+// SendFormatted отправляет текст с указанным parse_mode, например собранный lib/format.
+func (c *Client) SendFormatted(chatID int, text string, mode format.Mode) (err error) {
+	defer func() { err = e.WrapIfErr("can't send message", err) }()
+
 	q := url.Values{}
 	q.Add("chat_id", strconv.Itoa(chatID))
 	q.Add("text", text)
-	q.Add("parse_mode", "Markdown")
+	q.Add("parse_mode", string(mode))
 
-	_, err := c.doTgRequest(sendMessageMethod, q)
-	if err != nil {
-		return e.Warp("can't send message", err)
-	}
+	return c.send(sendMessageMethod, chatID, q)
+}
 
-	return nil
+// SendPlainMessage отправляет текст без разметки.
+func (c *Client) SendPlainMessage(chatID int, text string) (err error) {
+	defer func() { err = e.WrapIfErr("can't send message", err) }()
+
+	q := url.Values{}
+	q.Add("chat_id", strconv.Itoa(chatID))
+	q.Add("text", text)
+
+	return c.send(sendMessageMethod, chatID, q)
 }
 
-func (c *Client) Game(gameId string) (g GameData, err error) {
-	link := fmt.Sprintf("https://store.steampowered.com/api/appdetails?appids=%s&cc=ru&l=ru", gameId)
-	body, err := c.doSteamReq(link)
-	if err != nil {
-		return g, e.Warp("can't import game", err)
-	}
-	var result map[string]GameResponse
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return g, err
-	}
+// SendKeyboard отправляет сообщение с инлайн клавиатурой, пустой mode - без разметки.
+func (c *Client) SendKeyboard(chatID int, text string, mode format.Mode, keyboard *InlineKeyboard) (err error) {
+	defer func() { err = e.WrapIfErr("can't send message", err) }()
 
-	gameResp, ok := result[gameId]
-	if !ok || !gameResp.Success {
-		return GameData{}, fmt.Errorf("game not found or unsuccessful response")
-	}
-	if gameResp.Data.Price.Final == "" {
-		gameResp.Data.Price.Final = "бесплатно"
+	q := url.Values{}
+	q.Add("chat_id", strconv.Itoa(chatID))
+	q.Add("text", text)
+	if mode != "" {
+		q.Add("parse_mode", string(mode))
 	}
-	if gameResp.Data.Price.Initial == "" {
-		gameResp.Data.Price.Initial = gameResp.Data.Price.Final
+	if err := addKeyboard(q, keyboard); err != nil {
+		return err
 	}
-	return gameResp.Data, nil
+
+	return c.send(sendMessageMethod, chatID, q)
 }
 
-func (c *Client) Sale() (g []GameInfo, err error) {
-	link := fmt.Sprintf("https://store.steampowered.com/search/?filter=weeklongdeals")
-	body, err := c.doSteamReq(link)
-	if err != nil {
-		return g, e.Warp("can't import game", err)
+// EditMessage заменяет текст и клавиатуру уже отправленного ботом сообщения.
+func (c *Client) EditMessage(chatID int, messageID int, text string, mode format.Mode, keyboard *InlineKeyboard) (err error) {
+	defer func() { err = e.WrapIfErr("can't edit message", err) }()
+
+	q := url.Values{}
+	q.Add("chat_id", strconv.Itoa(chatID))
+	q.Add("message_id", strconv.Itoa(messageID))
+	q.Add("text", text)
+	if mode != "" {
+		q.Add("parse_mode", string(mode))
 	}
-	games, err := c.parseGamesSale(body)
-	return games, nil
+	if err := addKeyboard(q, keyboard); err != nil {
+		return err
+	}
+
+	return c.send(editMessageTextMethod, chatID, q)
 }
 
-func (c *Client) doSteamReq(link string) (data []byte, err error) {
-	defer func() { err = e.WrapIfErr("can't do request", err) }()
+// AnswerCallbackQuery убирает часики с нажатой кнопки, непустой text показывается всплывающим уведомлением.
+func (c *Client) AnswerCallbackQuery(callbackID string, text string) (err error) {
+	defer func() { err = e.WrapIfErr("can't answer callback query", err) }()
 
-	req, err := http.NewRequest(http.MethodGet, link, nil)
-	req.Header.Set("X-Forwarded-For", "213.180.204.3")
-	req.Header.Add("Accept-Language", "ru")
-	if err != nil {
-		return data, err
+	q := url.Values{}
+	q.Add("callback_query_id", callbackID)
+	if text != "" {
+		q.Add("text", text)
 	}
 
-	resp, err := c.client.Do(req)
+	_, err = c.doTgRequest(answerCallbackQueryMethod, q)
+	return err
+}
+
+func addKeyboard(q url.Values, keyboard *InlineKeyboard) error {
+	if keyboard == nil {
+		return nil
+	}
+	data, err := json.Marshal(keyboard)
 	if err != nil {
-		return data, err
+		return err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	q.Add("reply_markup", string(data))
+	return nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return data, err
+// send ждет своей очереди по лимитам Telegram и повторяет отправку, если получил retry_after.
+func (c *Client) send(method string, chatID int, q url.Values) (err error) {
+	for attempt := 0; ; attempt++ {
+		c.queue.wait(chatID)
+
+		_, err = c.doTgRequest(method, q)
+
+		var apiErr *APIError
+		if !errors.Is(err, ErrFlood) || !errors.As(err, &apiErr) || attempt >= maxSendRetries {
+			return err
+		}
+		log.Printf("telegram flood wait for chat %d: retry %d/%d in %v", chatID, attempt+1, maxSendRetries, apiErr.RetryAfter)
+		c.queue.pause(chatID, max(apiErr.RetryAfter, time.Second))
 	}
-	return body, nil
 }
 
 func (c *Client) doTgRequest(method string, query url.Values) (data []byte, err error) {
@@ -157,40 +248,13 @@ func (c *Client) doTgRequest(method string, query url.Values) (data []byte, err
 		return nil, err
 	}
 
-	return body, nil
-}
-
-func (c *Client) parseGamesSale(body []byte) ([]GameInfo, error) {
-	var games []GameInfo
-
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
-	if err != nil {
+	var res Response
+	if err := json.Unmarshal(body, &res); err != nil {
 		return nil, err
 	}
+	if !res.Ok {
+		return nil, newAPIError(res)
+	}
 
-	doc.Find(".search_result_row").Each(func(i int, s *goquery.Selection) {
-		title := strings.TrimSpace(s.Find(".title").Text())
-		href, _ := s.Attr("href")
-		
-		// Цены
-		finalPrice := strings.TrimSpace(s.Find(".discount_final_price").Text())
-		oldPrice := strings.TrimSpace(s.Find(".discount_original_price").Text())
-
-		if finalPrice == "" {
-			// Если нет скидки, пробуем просто .search_price
-			raw := strings.TrimSpace(s.Find(".search_price").Text())
-			finalPrice = strings.Join(strings.Fields(raw), " ")
-		}
-
-		game := GameInfo{
-			Title:      title,
-			OldPrice:   oldPrice,
-			FinalPrice: finalPrice,
-			URL:        href,
-		}
-
-		games = append(games, game)
-	})
-
-	return games, nil
+	return body, nil
 }
@@ -1,46 +1,49 @@
 package telegram
 
-type UpdatesResponse struct {
-	Ok     bool     `json:"ok"`
-	Result []Update `json:"result"`
-}
-type Update struct {
-	ID      int              `json:"update_id"`
-	Message *IncomingMessage `json:"message"`
+// Response общая обертка всех ответов Bot API.
+type Response struct {
+	Ok          bool                `json:"ok"`
+	ErrorCode   int                 `json:"error_code"`
+	Description string              `json:"description"`
+	Parameters  *ResponseParameters `json:"parameters"`
 }
 
-type IncomingMessage struct {
-	Text string `json:"text"`
-	From From   `json:"from"`
-	Chat Chat   `json:"chat"`
+type ResponseParameters struct {
+	RetryAfter int `json:"retry_after"`
 }
 
-type GameResponse struct {
-	Success bool     `json:"success"`
-	Data    GameData `json:"data"`
+type MeResponse struct {
+	Ok     bool `json:"ok"`
+	Result From `json:"result"`
 }
 
-type GameData struct {
-	Name        string    `json:"name"`
-	IsFree      bool      `json:"is_free"`
-	Description string    `json:"short_description"`
-	Languages   string    `json:"supported_languages"`
-	Price       GamePrice `json:"price_overview"`
+type UpdatesResponse struct {
+	Ok     bool     `json:"ok"`
+	Result []Update `json:"result"`
+}
+type Update struct {
+	ID            int              `json:"update_id"`
+	Message       *IncomingMessage `json:"message"`
+	CallbackQuery *CallbackQuery   `json:"callback_query"`
 }
 
-type GameInfo struct {
-	Title      string
-	OldPrice   string
-	FinalPrice string
-	URL        string
+type IncomingMessage struct {
+	MessageID int    `json:"message_id"`
+	Text      string `json:"text"`
+	From      From   `json:"from"`
+	Chat      Chat   `json:"chat"`
 }
 
-type GamePrice struct {
-	Initial string `json:"initial_formatted"`
-	Final   string `json:"final_formatted"`
+// CallbackQuery нажатие на кнопку инлайн клавиатуры, Message - сообщение с этой клавиатурой.
+type CallbackQuery struct {
+	ID      string           `json:"id"`
+	From    From             `json:"from"`
+	Message *IncomingMessage `json:"message"`
+	Data    string           `json:"data"`
 }
 
 type From struct {
+	ID       int    `json:"id"`
 	Username string `json:"username"`
 }
 
@@ -0,0 +1,98 @@
+package telegram
+
+import (
+	"SteamSaleBot/clients/telegram"
+	"SteamSaleBot/events"
+	"SteamSaleBot/lib/e"
+	"SteamSaleBot/lib/format"
+	"errors"
+	"log"
+	"strings"
+)
+
+// callbackSep разделяет действие и аргументы в callback_data кнопки: "action:arg1:arg2".
+const callbackSep = ":"
+
+const (
+	settingsAction   = "settings"
+	myGamesAction    = "games"
+	removeGameAction = "rm"
+	gameInfoAction   = "info"
+	addGameAction    = "add"
+)
+
+// storeAppURL страница игры в магазине для кнопок со ссылкой.
+const storeAppURL = "https://store.steampowered.com/app/"
+
+var ErrUnknownCallback = errors.New("unknown callback")
+
+// callbackData собирает callback_data для кнопки, обработчик получит args через parseCallback.
+func callbackData(action string, args ...string) string {
+	return strings.Join(append([]string{action}, args...), callbackSep)
+}
+
+func parseCallback(data string) (action string, args []string) {
+	parts := strings.Split(data, callbackSep)
+	return parts[0], parts[1:]
+}
+
+func (p *Processor) processCallback(event events.Event) (err error) {
+	defer func() { err = e.WrapIfErr("can't process callback", err) }()
+
+	meta, err := meta(event)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("got new callback: %s from %s", event.Text, meta.Username)
+
+	answer, err := p.doCallback(event.Text, meta)
+	// на нажатие нужно ответить в любом случае, иначе кнопка так и останется с часиками
+	if err1 := p.tg.AnswerCallbackQuery(meta.CallbackID, answer); err1 != nil {
+		log.Println("can't answer callback", err1)
+	}
+	return err
+}
+
+// doCallback выполняет действие кнопки и возвращает текст всплывающего ответа.
+func (p *Processor) doCallback(data string, meta Meta) (string, error) {
+	action, args := parseCallback(data)
+	switch {
+	case action == settingsAction && len(args) == 1:
+		return "", p.toggleSetting(meta, args[0])
+	case action == myGamesAction && len(args) == 1:
+		return "", p.showMyGames(meta, args[0])
+	case action == removeGameAction && len(args) == 2:
+		return p.removeGame(meta, args[0], args[1])
+	case action == gameInfoAction && len(args) == 1:
+		return "", p.sendCheck(meta.ChatID, args[0])
+	case action == addGameAction && len(args) >= 1:
+		// после id может идти цель из /add: "add:312520:target:300"
+		return "", p.AddImport(meta.ChatID, strings.Join(args, " "), meta.UserID)
+	default:
+		return msgUnknownButton, ErrUnknownCallback
+	}
+}
+
+// sendKeyboard отправляет сообщение, собранное newMsg, вместе с клавиатурой.
+func (p *Processor) sendKeyboard(chatID int, text string, keyboard *telegram.InlineKeyboard) error {
+	err := p.tg.SendKeyboard(chatID, text, parseMode, keyboard)
+	if errors.Is(err, telegram.ErrBadMarkup) {
+		log.Println("bad markup, sending as plain text", err)
+		return p.tg.SendKeyboard(chatID, format.Strip(parseMode, text), "", keyboard)
+	}
+	return err
+}
+
+// editKeyboard заменяет сообщение с клавиатурой, повторное нажатие без изменений не считается ошибкой.
+func (p *Processor) editKeyboard(chatID int, messageID int, text string, keyboard *telegram.InlineKeyboard) error {
+	err := p.tg.EditMessage(chatID, messageID, text, parseMode, keyboard)
+	if errors.Is(err, telegram.ErrBadMarkup) {
+		log.Println("bad markup, editing as plain text", err)
+		err = p.tg.EditMessage(chatID, messageID, format.Strip(parseMode, text), "", keyboard)
+	}
+	if errors.Is(err, telegram.ErrNotModified) {
+		return nil
+	}
+	return err
+}
@@ -1,15 +1,20 @@
 package telegram
 
 import (
+	"SteamSaleBot/clients/steam"
+	"SteamSaleBot/clients/telegram"
 	"SteamSaleBot/lib/e"
 	"SteamSaleBot/storage"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 const (
@@ -21,246 +26,599 @@ const (
 	DonateCmd    = "/donate"
 	DeleteCmd    = "/delete"
 	CheckMyGames = "/my_games"
+	HistoryCmd   = "/history"
+	CancelCmd    = "/cancel"
 )
 
-var queueAdd = make(map[int]string)
+// maxGamesPerCmd сколько игр можно передать одной командой.
+const maxGamesPerCmd = 20
 
-func (p *Processor) doCmd(text string, chatId int, username string) error {
-	text = strings.TrimSpace(text)
+// adminID пользователь, которому доступны служебные команды и уведомления.
+const adminID = 2134561992
 
-	log.Printf("got new command: %s from %s", text, username)
+// routes регистрирует команды бота. Команды с id игры без аргументов спрашивают его отдельным сообщением.
+func (p *Processor) routes() *router {
+	r := newRouter()
+	r.use(recoverPanic, logRequests, p.lookupUser)
 
-	b, err := p.InQueueCmd(chatId, text, username)
-	if err != nil {
-		return err
-	}
-	if b {
-		return nil
-	}
-	switch text {
-	case HelpCmd:
-		return p.sendHelp(chatId)
-	case StartCmd:
-		queueAdd[chatId] = ""
-		return p.sendStart(chatId, username)
-	case AddCmd:
-		if err := p.tg.SendMessage(chatId, msgSendID); err != nil {
-			return err
-		}
-		queueAdd[chatId] = "add"
-		return nil
-	case SettingsCmd:
-		if err := p.sendSettings(chatId, username); err != nil {
+	r.handle(StartCmd, func(req *Request) error {
+		return p.sendStart(req.ChatID, req.UserID, req.Username)
+	})
+	r.handle(HelpCmd, func(req *Request) error {
+		return p.sendHelp(req.ChatID)
+	}, "/commands")
+	r.handle(AddCmd, p.askIfEmpty(p.searchIfName(func(req *Request) error {
+		return p.AddImport(req.ChatID, req.Args, req.UserID)
+	})))
+	r.handle(DeleteCmd, p.askIfEmpty(func(req *Request) error {
+		return p.DeleteGame(req.ChatID, req.Args, req.UserID)
+	}), "/remove")
+	r.handle(CheckCmd, p.askIfEmpty(p.searchIfName(func(req *Request) error {
+		return p.checkGames(req.ChatID, req.Args)
+	})))
+	r.handle(HistoryCmd, p.askIfEmpty(func(req *Request) error {
+		return p.sendHistory(req.ChatID, req.Args)
+	}), "/prices")
+	r.handle(SettingsCmd, p.sendSettings)
+	r.handle(CheckMyGames, func(req *Request) error {
+		return p.sendMyGames(req.ChatID, req.UserID)
+	}, "/mygames", "/games")
+	r.handle(DonateCmd, func(req *Request) error {
+		return p.sendDonate(req.ChatID)
+	})
+	r.handle(CancelCmd, p.cancelDialog)
+	return r
+}
+
+func (p *Processor) doCmd(text string, meta Meta) error {
+	cmd, bot, args, ok := parseCommand(text)
+	if !ok {
+		// обычный текст - ответ на вопрос команды, например id игры после /add
+		if cmd, ok := p.dialogs.take(meta.ChatID); ok {
+			_, err := p.router.dispatch(&Request{Meta: meta, Cmd: cmd, Args: strings.TrimSpace(text)})
 			return err
 		}
-		queueAdd[chatId] = "settings"
 		return nil
-	case DonateCmd:
-		return p.sendDonate(chatId)
-	case CheckCmd:
-		if err := p.tg.SendMessage(chatId, msgSendID); err != nil {
-			return err
-		}
-		queueAdd[chatId] = "check"
+	}
+	if bot != "" && !p.isMe(bot) {
+		// в группе команда адресована другому боту
 		return nil
+	}
 
-	case DeleteCmd:
-		if err := p.tg.SendMessage(chatId, msgSendID); err != nil {
-			return err
+	if cmd != CancelCmd {
+		// новая команда прерывает незаконченный диалог
+		p.dialogs.cancel(meta.ChatID)
+	}
+	found, err := p.router.dispatch(&Request{Meta: meta, Cmd: cmd, Args: args})
+	if !found {
+		log.Printf("unknown command %s from %s", cmd, meta.Username)
+	}
+	return err
+}
+
+// isMe сообщает, что name - имя этого бота. Если Telegram недоступен, считаем что команда наша.
+func (p *Processor) isMe(name string) bool {
+	if p.botName == "" {
+		me, err := p.tg.Me()
+		if err != nil {
+			log.Println("can't get bot name", err)
+			return true
 		}
-		queueAdd[chatId] = "delete"
+		p.botName = me.Username
+	}
+	return strings.EqualFold(name, p.botName)
+}
 
-	case CheckMyGames:
-		return p.sendMyGames(chatId, username)
+// askID просит id игры и ждет его следующим сообщением для cmd.
+func (p *Processor) askID(chatId int, cmd string) error {
+	msg := msgSendID
+	if cmd == AddCmd || cmd == CheckCmd {
+		msg = msgSendIDOrName
+	}
+	if err := p.tg.SendMessage(chatId, msg); err != nil {
+		return err
 	}
+	p.dialogs.start(chatId, cmd)
 	return nil
 }
 
-func (p *Processor) AddImport(chatId int, gameID string, username string) (err error) {
+func (p *Processor) cancelDialog(req *Request) error {
+	if p.dialogs.cancel(req.ChatID) {
+		return p.tg.SendMessage(req.ChatID, msgCanceled)
+	}
+	return p.tg.SendMessage(req.ChatID, msgNothingToCancel)
+}
+
+// AddImport сохраняет одну или несколько игр из text и отвечает итогом по каждой.
+func (p *Processor) AddImport(chatId int, text string, userID int) (err error) {
 	defer func() { err = e.WrapIfErr("can't to command: add game", err) }()
-	data, err := p.tg.Game(gameID)
+	games, err := parseAddArgs(text)
 	if err != nil {
-		if err1 := p.tg.SendMessage(chatId, msgErrImport); err != nil {
-			return err1
+		return p.tg.SendMessage(chatId, msgBadTarget)
+	}
+
+	// пакет или набор раскрывается в несколько игр с той же целью
+	type addTarget struct {
+		game storage.Game
+		err  error
+	}
+	var targets []addTarget
+	seen := make(map[string]bool, len(games))
+	for _, g := range games {
+		ids, err := p.appIDs(g.ID)
+		if err != nil {
+			targets = append(targets, addTarget{game: g, err: err})
+			continue
+		}
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			g.ID = id
+			targets = append(targets, addTarget{game: g})
 		}
-		return err
 	}
-	user := storage.User{
-		UserName: username,
-		Game:     storage.Game{Name: data.Name, ID: gameID, Price: data.Price.Final},
+	if len(targets) > maxGamesPerCmd {
+		return p.tg.SendMessage(chatId, tooManyGames())
 	}
-	if err := p.storage.Save(&user); err != nil {
+
+	var errs []error
+	entries := make([]string, 0, len(targets))
+	for _, t := range targets {
+		target, err := t.game, t.err
+		msg := newMsg()
+		if len(targets) > 1 {
+			msg.Code(target.ID).Text(": ")
+		}
+		if err == nil {
+			// ошибки разбора ссылки - ошибки ввода, в лог попадают только ошибки Steam и хранилища
+			if err = p.addGame(userID, &target); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err != nil {
+			entries = append(entries, msg.Text(steamErrMsg(err, msgErrImport)).Line().String())
+			continue
+		}
+		msg.Text(msgSuccessImport + target.Name)
+		if target.HasTarget() {
+			msg.Line().Text(msgTarget + targetText(&target))
+		}
+		entries = append(entries, msg.Line().String())
+	}
+
+	if err := p.sendList(chatId, "", entries); err != nil {
 		return err
 	}
+	return errors.Join(errs...)
+}
 
-	msg := fmt.Sprintf(msgSuccessImport + data.Name)
-	if err := p.tg.SendMessage(chatId, msg); err != nil {
+// addGame дополняет target данными из Steam и сохраняет игру пользователю.
+func (p *Processor) addGame(userID int, target *storage.Game) error {
+	data, err := p.steam.Game(target.ID)
+	if err != nil {
 		return err
 	}
-	return nil
+	p.recordPrice(target.ID, data.Name, data.Price)
+	target.Name = data.Name
+	target.Price = data.Price.FinalFormatted
+	target.Final = data.Price.Final
+	target.Currency = data.Price.Currency
+	user := storage.User{
+		ID:   userID,
+		Game: *target,
+	}
+	return p.storage.Save(&user)
 }
 
 func (p *Processor) sendDonate(chatId int) (err error) {
 	return p.tg.SendMessage(chatId, msgDonate)
 }
 
-func (p *Processor) sendSettings(chatId int, username string) (err error) {
-	user, err := p.storage.Settings(username)
-	if err != nil {
-		return err
-	}
-	var (
-		sales, freeWeekend, discounts string
-	)
-	if user.UserSettings.Sales == true {
-		sales = "Да"
-	} else {
-		sales = "Нет"
+func (p *Processor) sendSettings(req *Request) error {
+	if req.User == nil {
+		return p.tg.SendMessage(req.ChatID, msgNeedStart)
 	}
+	text, keyboard := settingsMessage(&req.User.UserSettings)
+	return p.sendKeyboard(req.ChatID, text, keyboard)
+}
 
-	if user.UserSettings.FreeWeekend == true {
-		freeWeekend = "Да"
-	} else {
-		freeWeekend = "Нет"
-	}
+// settingsMessage рисует настройки кнопками, номер в callback_data совпадает с UserSettings.Toggle.
+func settingsMessage(set *storage.UserSettings) (string, *telegram.InlineKeyboard) {
+	text := newMsg().Bold("Настройки уведомлений:").Line().Line().
+		Text("Нажмите на настройку, чтобы включить или отключить ее")
 
-	if user.UserSettings.Discounts == true {
-		discounts = "Да"
-	} else {
-		discounts = "Нет"
-	}
-	msg := fmt.Sprintf(
-		"*Настройки уведомлений:*\n"+
-			"1. Распродажи: *%s* \n"+
-			"2. Ежедневные скидки: *%s* \n"+
-			"3. Скидки ваших игр: *%s* \n\n"+
-			"Чтобы изменить настроки напишите номера которые хотите отключить или включить через запятую\n\nЧтобы выйти без изменений напишите \"exit\" ", sales, freeWeekend, discounts)
+	keyboard := telegram.NewKeyboard(
+		telegram.Row(settingButton("Распродажи", set.Sales, "1")),
+		telegram.Row(settingButton("Ежедневные скидки", set.FreeWeekend, "2")),
+		telegram.Row(settingButton("Скидки ваших игр", set.Discounts, "3")),
+		telegram.Row(settingButton("Исторический минимум цены", set.HistoricalLow, "4")),
+	)
+	return text.String(), keyboard
+}
 
-	if err := p.tg.SendMessage(chatId, msg); err != nil {
+func settingButton(name string, on bool, toggle string) telegram.InlineButton {
+	state := "❌"
+	if on {
+		state = "✅"
 	}
-	return err
+	return telegram.CallbackButton(name+": "+state, callbackData(settingsAction, toggle))
 }
 
-func (p *Processor) updSettings(chatId int, username, settings string) (err error) {
-	defer func() { err = e.WrapIfErr("can't to command: UpdSettings", err) }()
-	settings = strings.ReplaceAll(settings, " ", "")
-	if settings == "exit" {
-		return nil
-	}
-	parts := strings.Split(settings, ",")
-	if len(parts) <= 0 || len(parts) > 2 {
-		return p.tg.SendMessage(chatId, "Не правильное кол-во аргументов")
-	}
-	if _, err := strconv.Atoi(parts[0]); err != nil {
-		return p.tg.SendMessage(chatId, "Не правильное кол-во аргументов")
-	}
+// toggleSetting переключает настройку по нажатию кнопки и перерисовывает меню в том же сообщении.
+func (p *Processor) toggleSetting(meta Meta, toggle string) (err error) {
+	defer func() { err = e.WrapIfErr("can't to command: toggle setting", err) }()
 
-	if err := p.storage.UpdSettings(username, parts); err != nil {
+	if err := p.storage.UpdSettings(meta.UserID, []string{toggle}); err != nil {
+		return err
+	}
+	user, err := p.storage.Settings(meta.UserID)
+	if err != nil {
 		return err
 	}
-	return p.tg.SendMessage(chatId, msgSuccessEdit)
+	text, keyboard := settingsMessage(&user.UserSettings)
+	return p.editKeyboard(meta.ChatID, meta.MessageID, text, keyboard)
 }
 
 func (p *Processor) sendCheck(chatId int, gameID string) (err error) {
 	defer func() { err = e.WrapIfErr("can't to command: send random", err) }()
 
-	data, err := p.tg.Game(gameID)
+	data, err := p.steam.Game(gameID)
 	if err != nil {
+		if err1 := p.tg.SendMessage(chatId, steamErrMsg(err, msgNotExist)); err1 != nil {
+			return err1
+		}
 		return err
 	}
 
+	p.recordPrice(gameID, data.Name, data.Price)
+
 	re := regexp.MustCompile(`(?:<strong>\*</strong>)|(?:<br><strong>\*</strong>.*)`)
 	data.Languages = re.ReplaceAllString(data.Languages, "")
 
-	msg := fmt.Sprintf(
-		"*Название:* %s \n\n"+
-			"*Описание:* %s \n\n"+
-			"*Цена без скидки:* %s \n\n"+
-			"*Цена со скидкой:* %s \n\n"+
-			"*Поддерживаемые языки:* %s", data.Name, data.Description, data.Price.Initial, data.Price.Final, data.Languages)
+	msg := newMsg().
+		Bold("Название:").Text(" " + data.Name).Line().Line().
+		Bold("Описание:").Text(" " + data.Description).Line().Line().
+		Bold("Цена без скидки:").Text(" " + data.Price.InitialFormatted).Line().Line().
+		Bold("Цена со скидкой:").Text(" " + data.Price.FinalFormatted).Line().Line().
+		Bold("Поддерживаемые языки:").Text(" " + data.Languages)
 
-	return p.tg.SendMessage(chatId, msg)
+	return p.sendMessage(chatId, msg.String())
 }
 
-func (p *Processor) sendMyGames(chatId int, username string) (err error) {
+func (p *Processor) sendMyGames(chatId int, userID int) (err error) {
 	defer func() { err = e.WrapIfErr("can't to command: send game", err) }()
-	games, err := p.storage.CheckAllGame(username)
-	if len(games) == 0 {
-		return p.tg.SendMessage(chatId, msgNoSavedPages)
+
+	text, keyboard, err := p.myGamesPage(userID, 0)
+	if err != nil {
+		return err
 	}
+	return p.sendKeyboard(chatId, text, keyboard)
+}
 
+// myGamesPage рисует страницу /my_games: у каждой игры кнопки удаления, подробностей и ссылки в Steam,
+// внизу переход между страницами. Без игр возвращает msgNoSavedPages без клавиатуры.
+func (p *Processor) myGamesPage(userID int, page int) (string, *telegram.InlineKeyboard, error) {
+	games, err := p.storage.CheckAllGame(userID)
+	// у пользователя без игр в файловом хранилище нет и папки games
+	if errors.Is(err, storage.ErrNotSavedGame) || errors.Is(err, os.ErrNotExist) {
+		return newMsg().Text(msgNoSavedPages).String(), nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// порядок хранилища не гарантирован, а страницы не должны меняться между нажатиями
+	sort.Slice(games, func(i, j int) bool {
+		if games[i].Name != games[j].Name {
+			return games[i].Name < games[j].Name
+		}
+		return games[i].ID < games[j].ID
+	})
+
+	pages := (len(games) + myGamesPageSize - 1) / myGamesPageSize
+	page = min(max(page, 0), pages-1)
+	first := page * myGamesPageSize
+	games = games[first:min(first+myGamesPageSize, len(games))]
+
+	msg := newMsg()
+	keyboard := telegram.NewKeyboard()
+	for i, game := range games {
+		n := strconv.Itoa(first + i + 1)
+		// если Steam не ответил, показываем последнюю сохраненную цену
+		price := game.Price
+		if data, err := p.steam.Game(game.ID); err != nil {
+			log.Println("can't get game", err)
+		} else {
+			price = data.Price.FinalFormatted
+		}
+		msg.Bold(n + ". " + game.Name).Line().
+			Bold("ID игры:").Text(" ").Code(game.ID).Line().
+			Bold("Актуальная цена:").Text(" " + price).Line()
+		if game.HasTarget() {
+			msg.Bold("Цель:").Text(" " + targetText(game)).Line()
+		}
+		msg.Line()
+
+		keyboard.Rows = append(keyboard.Rows, telegram.Row(
+			telegram.CallbackButton("❌ "+n, callbackData(removeGameAction, game.ID, strconv.Itoa(page))),
+			telegram.CallbackButton("ℹ️ "+n, callbackData(gameInfoAction, game.ID)),
+			telegram.URLButton("Steam "+n, storeAppURL+game.ID),
+		))
+	}
+
+	if pages > 1 {
+		nav := telegram.Row()
+		if page > 0 {
+			nav = append(nav, telegram.CallbackButton("◀️", callbackData(myGamesAction, strconv.Itoa(page-1))))
+		}
+		nav = append(nav, telegram.CallbackButton(fmt.Sprintf("%d/%d", page+1, pages), callbackData(myGamesAction, strconv.Itoa(page))))
+		if page < pages-1 {
+			nav = append(nav, telegram.CallbackButton("▶️", callbackData(myGamesAction, strconv.Itoa(page+1))))
+		}
+		keyboard.Rows = append(keyboard.Rows, nav)
+	}
+	return msg.String(), keyboard, nil
+}
+
+// showMyGames перерисовывает /my_games на странице page в том же сообщении.
+func (p *Processor) showMyGames(meta Meta, page string) (err error) {
+	defer func() { err = e.WrapIfErr("can't to command: show games page", err) }()
+
+	n, err := strconv.Atoi(page)
 	if err != nil {
 		return err
 	}
+	text, keyboard, err := p.myGamesPage(meta.UserID, n)
 	if err != nil {
 		return err
 	}
-	msg := ""
-	for _, game := range games {
-		data, err := p.tg.Game(game.ID)
-		if err != nil {
-			return err
+	return p.editKeyboard(meta.ChatID, meta.MessageID, text, keyboard)
+}
+
+// removeGame удаляет игру по кнопке из /my_games и возвращает текст всплывающего ответа.
+func (p *Processor) removeGame(meta Meta, gameID string, page string) (answer string, err error) {
+	defer func() { err = e.WrapIfErr("can't to command: remove game", err) }()
+
+	var name string
+	if games, err := p.storage.CheckAllGame(meta.UserID); err == nil {
+		for _, g := range games {
+			if g.ID == gameID {
+				name = g.Name
+			}
 		}
-		msg += fmt.Sprintf(
-			"*ID игры:* `%s` \n"+
-				"*Название игры:* %s \n"+
-				"*Актуальная цена:* %s \n\n", game.ID, game.Name, data.Price.Final)
 	}
-	return p.tg.SendMessage(chatId, msg)
+
+	user := storage.User{ID: meta.UserID, Game: storage.Game{ID: gameID}}
+	answer = msgDeleteGame + name
+	if err := p.storage.Remove(&user); errors.Is(err, os.ErrNotExist) {
+		// игру уже удалили из другого сообщения, просто обновляем список
+		answer = msgNotExist
+	} else if err != nil {
+		return "", err
+	}
+	return answer, p.showMyGames(meta, page)
 }
 
 func (p *Processor) sendHelp(chatId int) error {
 	return p.tg.SendMessage(chatId, msgHelp)
 }
 
-func (p *Processor) sendStart(chatId int, name string) error {
-	g := storage.User{UserName: name, UserSettings: storage.UserSettings{ChatId: chatId}}
+func (p *Processor) sendStart(chatId int, userID int, name string) error {
+	g := storage.User{ID: userID, UserName: name, UserSettings: storage.UserSettings{ChatId: chatId}}
 	if err := p.storage.CreateSettings(&g); err != nil {
 		return err
 	}
+	// пользователь мог заблокировать бота и вернуться
+	if err := p.storage.SetActive(userID, true); err != nil {
+		return err
+	}
 	return p.tg.SendMessage(chatId, msgHello)
 }
 
-func (p *Processor) DeleteGame(chatId int, GameID string, username string) error {
-	data, _ := p.tg.Game(GameID)
-	user := storage.User{
-		UserName: username,
-		Game:     storage.Game{ID: GameID, Name: data.Name},
+// DeleteGame удаляет одну или несколько игр из text и отвечает итогом по каждой.
+func (p *Processor) DeleteGame(chatId int, text string, userID int) error {
+	refs := p.gameRefs(text)
+	if len(refs) > maxGamesPerCmd {
+		return p.tg.SendMessage(chatId, tooManyGames())
 	}
-	msg := fmt.Sprintf(msgDeleteGame + data.Name)
-	if err := p.storage.Remove(&user); errors.Is(err, os.ErrNotExist) {
-		return p.tg.SendMessage(chatId, msgNotExist)
+
+	var errs []error
+	entries := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		msg := newMsg()
+		if len(refs) > 1 {
+			msg.Code(ref.id).Text(": ")
+		}
+		if ref.err != nil {
+			entries = append(entries, msg.Text(steamErrMsg(ref.err, msgNotExist)).Line().String())
+			continue
+		}
+		id := ref.id
+		// название нужно только для ответа, удаляем игру даже если Steam недоступен
+		data, _ := p.steam.Game(id)
+		user := storage.User{
+			ID:   userID,
+			Game: storage.Game{ID: id, Name: data.Name},
+		}
+		switch err := p.storage.Remove(&user); {
+		case errors.Is(err, os.ErrNotExist):
+			msg.Text(msgNotExist)
+		case err != nil:
+			errs = append(errs, e.Warp("can't to command: delete game", err))
+			msg.Text(msgErrDelete)
+		default:
+			msg.Text(msgDeleteGame + data.Name)
+		}
+		entries = append(entries, msg.Line().String())
 	}
-	if err := p.tg.SendMessage(chatId, msg); err != nil {
+
+	if err := p.sendList(chatId, "", entries); err != nil {
 		return err
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// checkGames отправляет карточку каждой игры из text.
+func (p *Processor) checkGames(chatId int, text string) error {
+	refs := p.gameRefs(text)
+	if len(refs) > maxGamesPerCmd {
+		return p.tg.SendMessage(chatId, tooManyGames())
+	}
+
+	var errs []error
+	for _, ref := range refs {
+		if ref.err != nil {
+			msg := newMsg().Code(ref.id).Text(": " + steamErrMsg(ref.err, msgNotExist))
+			if err := p.sendMessage(chatId, msg.String()); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := p.sendCheck(chatId, ref.id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// gameRef аргумент команды, приведенный к id приложения Steam. Если не вышло, id - исходный текст, а err - причина.
+type gameRef struct {
+	id  string
+	err error
+}
+
+// gameRefs делит аргументы команды по пробелам и запятым на id игр или ссылки на них, убирая повторы.
+// Пакет или набор дает по gameRef на каждую игру в нем.
+func (p *Processor) gameRefs(text string) []gameRef {
+	fields := strings.FieldsFunc(text, isArgSep)
+	seen := make(map[string]bool, len(fields))
+	refs := make([]gameRef, 0, len(fields))
+	for _, field := range fields {
+		ids, err := p.appIDs(field)
+		if err != nil {
+			ids = []string{field}
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				refs = append(refs, gameRef{id: id, err: err})
+			}
+		}
+	}
+	return refs
+}
+
+// appIDs приводит id или ссылку к id приложений, у пакета или набора их несколько.
+func (p *Processor) appIDs(arg string) ([]string, error) {
+	ref, err := steam.ParseRef(arg)
+	if err != nil {
+		return nil, err
+	}
+	return p.steam.Apps(ref)
+}
+
+func isArgSep(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
 }
 
-func (p *Processor) InQueueCmd(chatId int, text string, username string) (bool, error) {
-	for _, f := range queueAdd {
-		switch f {
-		case "add":
-			queueAdd[chatId] = ""
-			if err := p.AddImport(chatId, text, username); err != nil {
-				return true, err
+// splitArgs делит аргументы по пробелам и запятым, но цену после target оставляет целой: "target 299,99".
+func splitArgs(text string) []string {
+	var args []string
+	afterTarget := false
+	for _, field := range strings.Fields(text) {
+		if afterTarget {
+			afterTarget = false
+			args = append(args, strings.Trim(field, ","))
+			continue
+		}
+		parts := strings.FieldsFunc(field, isArgSep)
+		args = append(args, parts...)
+		afterTarget = len(parts) > 0 && isTargetKeyword(parts[len(parts)-1])
+	}
+	return args
+}
+
+func isTargetKeyword(arg string) bool {
+	arg = strings.ToLower(arg)
+	return arg == "target" || arg == "цель"
+}
+
+// isTargetDiscount похож ли аргумент на цель-скидку "50%", диапазон проверяет parseAddArgs.
+func isTargetDiscount(arg string) bool {
+	n, ok := strings.CutSuffix(arg, "%")
+	_, err := strconv.Atoi(n)
+	return ok && err == nil
+}
+
+// parseAddArgs разбирает список игр, после каждой можно указать цель:
+// "312520", "312520 target 300", "312520 50%, 1145360 730". Повторы одной игры объединяются,
+// а аргумент, который не id, не ссылка и не цель, - ошибка.
+func parseAddArgs(text string) ([]storage.Game, error) {
+	var games []storage.Game
+	index := make(map[steam.Ref]int)
+	cur := -1 // игра, к которой относится цель
+	args := splitArgs(text)
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToLower(args[i])
+		switch {
+		case isTargetKeyword(arg):
+			if cur < 0 || i+1 >= len(args) {
+				return nil, errors.New("no target price")
 			}
-		case "delete":
-			queueAdd[chatId] = ""
-			if err := p.DeleteGame(chatId, text, username); err != nil {
-				return true, err
+			i++
+			price, err := strconv.ParseFloat(strings.ReplaceAll(args[i], ",", "."), 64)
+			if err != nil || price <= 0 {
+				return nil, errors.New("bad target price")
 			}
-		case "check":
-			queueAdd[chatId] = ""
-			if err := p.sendCheck(chatId, text); err != nil {
-				return true, err
+			games[cur].TargetPrice = int(math.Round(price * 100))
+		case strings.HasSuffix(arg, "%"):
+			percent, err := strconv.Atoi(strings.TrimSuffix(arg, "%"))
+			if cur < 0 || err != nil || percent <= 0 || percent > 100 {
+				return nil, errors.New("bad target discount")
 			}
-		case "settings":
-			queueAdd[chatId] = ""
-			if err := p.updSettings(chatId, username, text); err != nil {
-				return true, err
+			games[cur].TargetDiscount = percent
+		default:
+			ref, err := steam.ParseRef(args[i])
+			if err != nil {
+				return nil, fmt.Errorf("unknown argument %q", args[i])
 			}
+			if n, ok := index[ref]; ok {
+				cur = n
+				continue
+			}
+			cur = len(games)
+			index[ref] = cur
+			games = append(games, storage.Game{ID: args[i]})
 		}
 	}
-	return false, nil
+	if len(games) == 0 {
+		return nil, errors.New("no game id")
+	}
+	return games, nil
+}
+
+// tooManyGames ответ на команду с числом игр больше maxGamesPerCmd.
+func tooManyGames() string {
+	return fmt.Sprintf(msgTooManyGames, maxGamesPerCmd)
+}
+
+func targetText(g *storage.Game) string {
+	parts := make([]string, 0, 2)
+	if g.TargetPrice > 0 {
+		parts = append(parts, fmt.Sprintf("цена не выше %s руб.", strconv.FormatFloat(float64(g.TargetPrice)/100, 'f', -1, 64)))
+	}
+	if g.TargetDiscount > 0 {
+		parts = append(parts, fmt.Sprintf("скидка от %d%%", g.TargetDiscount))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// steamErrMsg выбирает ответ пользователю: Steam временно недоступен или игра не найдена.
+func steamErrMsg(err error, notFound string) string {
+	if steam.IsUnavailable(err) {
+		return msgSteamUnavailable
+	}
+	return notFound
 }
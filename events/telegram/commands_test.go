@@ -0,0 +1,42 @@
+package telegram
+
+import (
+	"SteamSaleBot/storage"
+	"reflect"
+	"testing"
+)
+
+func TestParseAddArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []storage.Game
+		wantErr bool
+	}{
+		{"one id", "312520", []storage.Game{{ID: "312520"}}, false},
+		{"comma list", "312520,1145360", []storage.Game{{ID: "312520"}, {ID: "1145360"}}, false},
+		{"target with decimal comma", "312520 target 299,99, 1145360",
+			[]storage.Game{{ID: "312520", TargetPrice: 29999}, {ID: "1145360"}}, false},
+		{"discount", "312520 50%", []storage.Game{{ID: "312520", TargetDiscount: 50}}, false},
+		{"duplicate keeps target", "312520, 312520 цель 300",
+			[]storage.Game{{ID: "312520", TargetPrice: 30000}}, false},
+		{"link", "https://store.steampowered.com/app/312520/Rain_World/",
+			[]storage.Game{{ID: "https://store.steampowered.com/app/312520/Rain_World/"}}, false},
+		{"typo in keyword", "312520 targt 300", nil, true},
+		{"target without game", "target 300", nil, true},
+		{"target without price", "312520 target", nil, true},
+		{"bad discount", "312520 150%", nil, true},
+		{"empty", " , ", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAddArgs(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAddArgs(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAddArgs(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
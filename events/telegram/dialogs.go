@@ -0,0 +1,135 @@
+package telegram
+
+import (
+	"SteamSaleBot/lib/e"
+	"encoding/gob"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// dialogTTL сколько бот ждет ответ на вопрос команды, после этого сообщение обрабатывается как обычное.
+const dialogTTL = 10 * time.Minute
+
+// dialogs хранит для каждого чата команду, которая ждет следующего сообщения (например id игры после /add).
+// Состояние переживает перезапуск, если задан path.
+type dialogs struct {
+	mu     sync.Mutex
+	path   string
+	ttl    time.Duration
+	states map[int]dialogState
+}
+
+type dialogState struct {
+	Cmd   string
+	Until time.Time
+}
+
+func newDialogs(path string, ttl time.Duration) *dialogs {
+	d := &dialogs{
+		path:   path,
+		ttl:    ttl,
+		states: make(map[int]dialogState),
+	}
+	if path != "" {
+		if err := d.load(); err != nil {
+			log.Println("can't load dialogs", err)
+		}
+	}
+	return d
+}
+
+// start запоминает, что следующее сообщение чата - ответ на cmd.
+func (d *dialogs) start(chatID int, cmd string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.states[chatID] = dialogState{Cmd: cmd, Until: time.Now().Add(d.ttl)}
+	d.save()
+}
+
+// take возвращает команду, ждущую ответа в чате, и завершает диалог.
+func (d *dialogs) take(chatID int) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.states[chatID]
+	if !ok {
+		return "", false
+	}
+	delete(d.states, chatID)
+	d.save()
+
+	if time.Now().After(state.Until) {
+		return "", false
+	}
+	return state.Cmd, true
+}
+
+// cancel завершает диалог чата и сообщает, был ли он.
+func (d *dialogs) cancel(chatID int) bool {
+	_, ok := d.take(chatID)
+	return ok
+}
+
+// save пишет состояние на диск, вызывается под mu.
+func (d *dialogs) save() {
+	if d.path == "" {
+		return
+	}
+	now := time.Now()
+	for id, state := range d.states {
+		if now.After(state.Until) {
+			delete(d.states, id)
+		}
+	}
+	if err := d.writeFile(); err != nil {
+		log.Println("can't save dialogs", err)
+	}
+}
+
+func (d *dialogs) writeFile() (err error) {
+	defer func() { err = e.WrapIfErr("can't write dialogs", err) }()
+
+	if err := os.MkdirAll(filepath.Dir(d.path), 0774); err != nil {
+		return err
+	}
+
+	tmp := d.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(file).Encode(d.states)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, d.path)
+}
+
+func (d *dialogs) load() (err error) {
+	defer func() { err = e.WrapIfErr("can't read dialogs", err) }()
+
+	file, err := os.Open(d.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	states := make(map[int]dialogState)
+	if err := gob.NewDecoder(file).Decode(&states); err != nil {
+		return err
+	}
+	d.states = states
+	return nil
+}
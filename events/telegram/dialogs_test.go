@@ -0,0 +1,88 @@
+package telegram
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDialogsPerChat(t *testing.T) {
+	d := newDialogs("", time.Minute)
+	d.start(1, AddCmd)
+	d.start(2, DeleteCmd)
+
+	if cmd, ok := d.take(2); !ok || cmd != DeleteCmd {
+		t.Fatalf("take(2) = %q, %v, want %q", cmd, ok, DeleteCmd)
+	}
+	if cmd, ok := d.take(1); !ok || cmd != AddCmd {
+		t.Fatalf("take(1) = %q, %v, want %q", cmd, ok, AddCmd)
+	}
+	if _, ok := d.take(1); ok {
+		t.Fatal("dialog is still pending after take")
+	}
+	if _, ok := d.take(3); ok {
+		t.Fatal("chat without dialog has a pending command")
+	}
+}
+
+func TestDialogsConcurrent(t *testing.T) {
+	d := newDialogs(filepath.Join(t.TempDir(), "dialogs"), time.Minute)
+	cmds := []string{AddCmd, DeleteCmd, CheckCmd, HistoryCmd}
+
+	var wg sync.WaitGroup
+	for chat := 0; chat < 50; chat++ {
+		wg.Add(1)
+		go func(chat int) {
+			defer wg.Done()
+			cmd := cmds[chat%len(cmds)]
+			for i := 0; i < 20; i++ {
+				d.start(chat, cmd)
+				if got, ok := d.take(chat); !ok || got != cmd {
+					t.Errorf("chat %d: take = %q, %v, want %q", chat, got, ok, cmd)
+					return
+				}
+				d.start(chat, cmd)
+				if !d.cancel(chat) {
+					t.Errorf("chat %d: cancel found no dialog", chat)
+					return
+				}
+			}
+		}(chat)
+	}
+	wg.Wait()
+}
+
+func TestDialogsExpire(t *testing.T) {
+	d := newDialogs("", 10*time.Millisecond)
+	d.start(1, AddCmd)
+	time.Sleep(20 * time.Millisecond)
+
+	if cmd, ok := d.take(1); ok {
+		t.Fatalf("expired dialog returned %q", cmd)
+	}
+	if d.cancel(1) {
+		t.Fatal("expired dialog was cancelled")
+	}
+}
+
+func TestDialogsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dialogs")
+	d := newDialogs(path, time.Minute)
+	d.start(1, AddCmd)
+	d.start(2, CheckCmd)
+
+	reloaded := newDialogs(path, time.Minute)
+	if cmd, ok := reloaded.take(1); !ok || cmd != AddCmd {
+		t.Fatalf("take(1) after reload = %q, %v, want %q", cmd, ok, AddCmd)
+	}
+
+	// take тоже сохраняется: после еще одного перезапуска остается только второй чат
+	reloaded = newDialogs(path, time.Minute)
+	if _, ok := reloaded.take(1); ok {
+		t.Fatal("taken dialog came back after reload")
+	}
+	if cmd, ok := reloaded.take(2); !ok || cmd != CheckCmd {
+		t.Fatalf("take(2) after reload = %q, %v, want %q", cmd, ok, CheckCmd)
+	}
+}
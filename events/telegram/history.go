@@ -0,0 +1,93 @@
+package telegram
+
+import (
+	"SteamSaleBot/clients/steam"
+	"SteamSaleBot/lib/e"
+	"SteamSaleBot/storage"
+	"errors"
+	"log"
+	"time"
+)
+
+const historyLimit = 10
+
+// recordPrice сохраняет цену в общую историю, если она изменилась с прошлого раза.
+func (p *Processor) recordPrice(appID string, name string, price steam.GamePrice) {
+	// у бесплатных игр и игр без цены нет price_overview
+	if price.Initial == 0 {
+		return
+	}
+
+	last, err := p.storage.Prices(appID, 1)
+	if err != nil && !errors.Is(err, storage.ErrNoPriceHistory) {
+		log.Println("can't get last price", err)
+		return
+	}
+	if len(last) > 0 &&
+		last[0].Initial == price.Initial &&
+		last[0].Final == price.Final &&
+		last[0].DiscountPercent == price.DiscountPercent {
+		return
+	}
+
+	rec := storage.Price{
+		AppID:            appID,
+		Name:             name,
+		Initial:          price.Initial,
+		Final:            price.Final,
+		DiscountPercent:  price.DiscountPercent,
+		InitialFormatted: price.InitialFormatted,
+		FinalFormatted:   price.FinalFormatted,
+		Time:             time.Now(),
+	}
+	if err := p.storage.AddPrice(&rec); err != nil {
+		log.Println("can't save price", err)
+	}
+}
+
+// sendHistory отправляет историю цен игры, для пакета или набора - каждой игры в нем.
+func (p *Processor) sendHistory(chatId int, text string) (err error) {
+	defer func() { err = e.WrapIfErr("can't to command: send history", err) }()
+
+	appIDs, err := p.appIDs(text)
+	if err != nil {
+		return p.tg.SendMessage(chatId, steamErrMsg(err, msgNoHistory))
+	}
+	if len(appIDs) > maxGamesPerCmd {
+		return p.tg.SendMessage(chatId, tooManyGames())
+	}
+
+	var errs []error
+	for _, appID := range appIDs {
+		if err := p.sendAppHistory(chatId, appID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Processor) sendAppHistory(chatId int, appID string) error {
+	prices, err := p.storage.Prices(appID, historyLimit)
+	if errors.Is(err, storage.ErrNoPriceHistory) {
+		return p.tg.SendMessage(chatId, msgNoHistory)
+	}
+	if err != nil {
+		return err
+	}
+	low, err := p.storage.LowestPrice(appID)
+	if err != nil {
+		return err
+	}
+
+	msg := newMsg().Bold("История цен:").Text(" " + prices[0].Name).Line().Line()
+	for _, pr := range prices {
+		msg.Text(pr.Time.Format("02.01.2006 15:04") + " — ").Bold(pr.FinalFormatted)
+		if pr.DiscountPercent > 0 {
+			msg.Textf(" (-%d%%, без скидки %s)", pr.DiscountPercent, pr.InitialFormatted)
+		}
+		msg.Line()
+	}
+	msg.Line().Bold("Минимальная цена:").Textf(" %s (%s)", low.FinalFormatted, low.Time.Format("02.01.2006"))
+
+	return p.sendMessage(chatId, msg.String())
+}
@@ -4,14 +4,16 @@ const msgHelp = `
 *Команды бота:*
 /start - перезапуск бота  
 /help - посмотреть команды  
-/add - добавить игру для уведомлений  
-/delete - удалить игру для уведомлений  
+/add - добавить игры для уведомлений: /add 312520 1145360 или по названию: /add Rain World, можно задать цель: /add 312520 target 300 или /add 312520 50%  
+/delete - удалить игры для уведомлений: /delete 312520 1145360  
 /my\_games - посмотреть список добавленных игр  
 /settings - настройки уведомлений  
-/check - проверить актуальную информацию о любой игре  
+/check - проверить актуальную информацию об играх: /check 312520 730 или /check Rain World  
+/history - история цен игры, например /history 312520  
+/cancel - отменить ввод после /add, /delete, /check или /history  
 /donate - поддержать автора  
 
-Чтобы быстро узнать ID игры в Steam, откройте страницу игры в браузере, и ID будет отображаться в URL страницы.  
+Вместо ID игры можно отправить ссылку на ее страницу в Steam или SteamDB.  
 
 Если что-то не работает или хотите что-то предложить, обратитесь к @Rayten225
 `
@@ -20,11 +22,22 @@ const msgHello = "Привет! Это бот для уведомлений о 
 const msgDonate = "Проект полностью бесплатный и держится на энтузиазме автора, если хотите его поддержать то можете перевести любую сумму: https://www.tbank.ru/cf/9OanthIoWie"
 
 const (
-	msgSuccessImport = "Игра сохранена: "
-	msgErrImport     = "Ошибка сохранения игры, неправильный id"
-	msgNoSavedPages  = "Нет сохраненых игр"
-	msgDeleteGame    = "Игра успешно удалена: "
-	msgSendID        = "Отправьте id игры"
-	msgNotExist      = "Игра не найдена"
-	msgSuccessEdit   = "Успешно изменено"
+	msgSuccessImport    = "Игра сохранена: "
+	msgErrImport        = "Ошибка сохранения игры, неправильный id"
+	msgNoSavedPages     = "Нет сохраненых игр"
+	msgDeleteGame       = "Игра успешно удалена: "
+	msgSendID           = "Отправьте id игры или ссылку на нее в Steam"
+	msgSendIDOrName     = "Отправьте id игры, ссылку на нее в Steam или название"
+	msgNothingFound     = "Ничего не найдено, попробуйте другое название"
+	msgNotExist         = "Игра не найдена"
+	msgNoHistory        = "Бот еще не видел цен на эту игру"
+	msgSteamUnavailable = "Steam временно недоступен, попробуйте позже"
+	msgBadTarget        = "Неправильный формат, пример: 312520 target 300 или 312520 50%"
+	msgTarget           = "Уведомим, когда "
+	msgUnknownButton    = "Кнопка устарела, вызовите команду заново"
+	msgCanceled         = "Действие отменено"
+	msgNothingToCancel  = "Нечего отменять"
+	msgNeedStart        = "Сначала отправьте /start"
+	msgErrDelete        = "Ошибка удаления игры, попробуйте позже"
+	msgTooManyGames     = "Слишком много игр за раз, отправьте не больше %d"
 )
@@ -0,0 +1,49 @@
+package telegram
+
+import (
+	"SteamSaleBot/clients/steam"
+	"SteamSaleBot/storage"
+	"log"
+	"time"
+)
+
+const (
+	priceBatchSize  = 50
+	priceBatchPause = 5 * time.Second
+)
+
+// fetchPrices собирает id игр всех пользователей без повторов и запрашивает цены пачками.
+// Игр, которые не удалось получить, в результате нет.
+func (p *Processor) fetchPrices(users map[*storage.User][]*storage.Game) map[string]steam.GamePrice {
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, games := range users {
+		for _, g := range games {
+			if seen[g.ID] {
+				continue
+			}
+			seen[g.ID] = true
+			ids = append(ids, g.ID)
+		}
+	}
+
+	prices := make(map[string]steam.GamePrice, len(ids))
+	for start := 0; start < len(ids); start += priceBatchSize {
+		if start > 0 {
+			time.Sleep(priceBatchPause)
+		}
+		end := min(start+priceBatchSize, len(ids))
+
+		batch, err := p.steam.Prices(ids[start:end])
+		if err != nil {
+			log.Println("can't get prices", err)
+			continue
+		}
+		for id, price := range batch {
+			prices[id] = price
+		}
+	}
+
+	log.Printf("got prices for %d of %d games", len(prices), len(ids))
+	return prices
+}
@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"SteamSaleBot/storage"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Request команда пользователя, разобранная роутером.
+type Request struct {
+	Meta
+	Cmd  string        // основное имя команды, даже если ее вызвали через псевдоним
+	Args string        // текст после команды
+	User *storage.User // заполняет lookupUser, nil если пользователь еще не отправлял /start
+}
+
+type HandlerFunc func(req *Request) error
+
+// Middleware оборачивает обработчик, например логирует или проверяет права.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+type router struct {
+	handlers   map[string]HandlerFunc
+	names      map[string]string // псевдоним -> основное имя
+	middleware []Middleware
+}
+
+func newRouter() *router {
+	return &router{
+		handlers: make(map[string]HandlerFunc),
+		names:    make(map[string]string),
+	}
+}
+
+// use добавляет middleware для всех команд, первый добавленный выполняется первым.
+func (r *router) use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// handle регистрирует обработчик команды cmd и ее псевдонимов.
+func (r *router) handle(cmd string, h HandlerFunc, aliases ...string) {
+	r.handlers[cmd] = h
+	for _, name := range append(aliases, cmd) {
+		r.names[name] = cmd
+	}
+}
+
+// dispatch выполняет команду req.Cmd и сообщает, была ли она зарегистрирована.
+func (r *router) dispatch(req *Request) (bool, error) {
+	cmd, ok := r.names[req.Cmd]
+	if !ok {
+		return false, nil
+	}
+	req.Cmd = cmd
+
+	h := r.handlers[cmd]
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	return true, h(req)
+}
+
+// parseCommand разбирает "/cmd@BotName args". Для текста без команды ok = false.
+func parseCommand(text string) (cmd string, bot string, args string, ok bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", "", "", false
+	}
+
+	end := strings.IndexFunc(text, unicode.IsSpace)
+	if end < 0 {
+		end = len(text)
+	}
+	cmd, args = text[:end], text[end:]
+	cmd, bot, _ = strings.Cut(cmd, "@")
+	return strings.ToLower(cmd), bot, strings.TrimSpace(args), true
+}
+
+// logRequests пишет в лог каждую команду, время ее выполнения и ошибку.
+func logRequests(next HandlerFunc) HandlerFunc {
+	return func(req *Request) error {
+		start := time.Now()
+		err := next(req)
+		log.Printf("command %s %q from %s (%d) done in %v, err: %v", req.Cmd, req.Args, req.Username, req.UserID, time.Since(start), err)
+		return err
+	}
+}
+
+// recoverPanic не дает панике в одном обработчике уронить бота.
+func recoverPanic(next HandlerFunc) HandlerFunc {
+	return func(req *Request) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("panic in command %s: %v\n%s", req.Cmd, r, debug.Stack())
+				err = fmt.Errorf("panic in command %s: %v", req.Cmd, r)
+			}
+		}()
+		return next(req)
+	}
+}
+
+// lookupUser загружает настройки пользователя в req.User.
+func (p *Processor) lookupUser(next HandlerFunc) HandlerFunc {
+	return func(req *Request) error {
+		// у нового пользователя настроек еще нет, это не ошибка
+		if u, err := p.storage.Settings(req.UserID); err == nil {
+			req.User = u
+		}
+		return next(req)
+	}
+}
+
+// adminOnly пропускает команду только от администратора, остальным она не видна.
+func adminOnly(next HandlerFunc) HandlerFunc {
+	return func(req *Request) error {
+		if req.UserID != adminID {
+			log.Printf("command %s from non-admin %s (%d) ignored", req.Cmd, req.Username, req.UserID)
+			return nil
+		}
+		return next(req)
+	}
+}
+
+// askIfEmpty для команды без аргументов спрашивает id игры и ждет его следующим сообщением.
+func (p *Processor) askIfEmpty(next HandlerFunc) HandlerFunc {
+	return func(req *Request) error {
+		if req.Args == "" {
+			return p.askID(req.ChatID, req.Cmd)
+		}
+		return next(req)
+	}
+}
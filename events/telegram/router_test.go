@@ -0,0 +1,83 @@
+package telegram
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text          string
+		cmd, bot, arg string
+		ok            bool
+	}{
+		{"/add 312520 730", "/add", "", "312520 730", true},
+		{"/ADD@SteamSaleBot  312520 ", "/add", "SteamSaleBot", "312520", true},
+		{"/check\n312520", "/check", "", "312520", true},
+		{"/my_games@other_bot", "/my_games", "other_bot", "", true},
+		{"312520", "", "", "", false},
+		{"", "", "", "", false},
+	}
+	for _, tt := range tests {
+		cmd, bot, args, ok := parseCommand(tt.text)
+		if cmd != tt.cmd || bot != tt.bot || args != tt.arg || ok != tt.ok {
+			t.Errorf("parseCommand(%q) = %q, %q, %q, %v, want %q, %q, %q, %v",
+				tt.text, cmd, bot, args, ok, tt.cmd, tt.bot, tt.arg, tt.ok)
+		}
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(req *Request) error {
+				calls = append(calls, name)
+				return next(req)
+			}
+		}
+	}
+
+	r := newRouter()
+	r.use(mw("first"), mw("second"))
+	r.handle("/delete", func(req *Request) error {
+		calls = append(calls, "handler "+req.Cmd+" "+req.Args)
+		return nil
+	}, "/remove")
+
+	found, err := r.dispatch(&Request{Cmd: "/remove", Args: "730"})
+	if !found || err != nil {
+		t.Fatalf("dispatch(/remove) = %v, %v", found, err)
+	}
+	want := []string{"first", "second", "handler /delete 730"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+
+	if found, err := r.dispatch(&Request{Cmd: "/unknown"}); found || err != nil {
+		t.Errorf("dispatch(/unknown) = %v, %v, want false, nil", found, err)
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	r := newRouter()
+	r.use(recoverPanic)
+	r.handle("/boom", func(req *Request) error { panic("boom") })
+
+	if _, err := r.dispatch(&Request{Cmd: "/boom"}); err == nil {
+		t.Fatal("panic was not turned into an error")
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	errCalled := errors.New("called")
+	h := adminOnly(func(req *Request) error { return errCalled })
+
+	if err := h(&Request{Meta: Meta{UserID: adminID + 1}}); err != nil {
+		t.Errorf("non-admin reached the handler: %v", err)
+	}
+	if err := h(&Request{Meta: Meta{UserID: adminID}}); !errors.Is(err, errCalled) {
+		t.Errorf("admin did not reach the handler: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package telegram
+
+import (
+	"SteamSaleBot/clients/steam"
+	"SteamSaleBot/clients/telegram"
+	"SteamSaleBot/lib/e"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// searchLimit сколько найденных игр предлагать на выбор.
+const searchLimit = 5
+
+// searchIfName для аргументов, которые не сводятся к id, ссылкам и целям, ищет игры по названию
+// и предлагает выбрать одну кнопками вместо выполнения команды.
+func (p *Processor) searchIfName(next HandlerFunc) HandlerFunc {
+	return func(req *Request) error {
+		if isName(req.Args) {
+			term, target := cutTarget(req.Args)
+			return p.sendSearch(req.ChatID, req.Cmd, term, target)
+		}
+		return next(req)
+	}
+}
+
+// isName сообщает, что в тексте есть аргумент, который не id игры, не ссылка на Steam и не цель,
+// значит это название: "7 Days to Die", "Rain World target 300".
+func isName(text string) bool {
+	args := splitArgs(text)
+	for i := 0; i < len(args); i++ {
+		switch {
+		case isTargetKeyword(args[i]):
+			i++ // цену проверит parseAddArgs
+		case isTargetDiscount(args[i]):
+		default:
+			if _, err := steam.ParseRef(args[i]); errors.Is(err, steam.ErrBadRef) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cutTarget отделяет цель в конце названия: "Rain World target 300 50%" -> "Rain World", [target 300 50%].
+func cutTarget(text string) (term string, target []string) {
+	fields := strings.Fields(text)
+	end := len(fields)
+	for end > 0 {
+		if isTargetDiscount(strings.Trim(fields[end-1], ",")) {
+			end--
+		} else if end >= 2 && isTargetKeyword(strings.Trim(fields[end-2], ",")) {
+			end -= 2
+		} else {
+			break
+		}
+	}
+	term = strings.TrimRight(strings.Join(fields[:end], " "), ",")
+	return term, splitArgs(strings.Join(fields[end:], " "))
+}
+
+// sendSearch отправляет найденные по term игры кнопками, нажатие выполняет cmd для выбранной игры.
+// target - цель из /add, она передается в кнопку вместе с id.
+func (p *Processor) sendSearch(chatId int, cmd string, term string, target []string) (err error) {
+	defer func() { err = e.WrapIfErr("can't to command: search games", err) }()
+
+	items, err := p.steam.Search(term)
+	if err != nil {
+		if err1 := p.tg.SendMessage(chatId, steamErrMsg(err, msgNothingFound)); err1 != nil {
+			return err1
+		}
+		return err
+	}
+	if len(items) == 0 {
+		return p.tg.SendMessage(chatId, msgNothingFound)
+	}
+
+	keyboard := telegram.NewKeyboard()
+	for _, item := range items[:min(len(items), searchLimit)] {
+		data := callbackData(gameInfoAction, strconv.Itoa(item.ID))
+		if cmd == AddCmd {
+			data = callbackData(addGameAction, append([]string{strconv.Itoa(item.ID)}, target...)...)
+		}
+		keyboard.Rows = append(keyboard.Rows, telegram.Row(
+			telegram.CallbackButton(item.Name, data),
+		))
+	}
+
+	msg := newMsg().Text("Найдено по запросу «" + term + "», выберите игру:")
+	return p.sendKeyboard(chatId, msg.String(), keyboard)
+}
@@ -0,0 +1,50 @@
+package telegram
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsName(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"312520", false},
+		{"312520, 1145360", false},
+		{"312520 target 299,99 50%", false},
+		{"https://store.steampowered.com/app/312520/Rain_World/", false},
+		{"store.steampowered.com/sub/469", false},
+		{"Rain World", true},
+		{"7 Days to Die", true},
+		{"60 Seconds!", true},
+		{"1000xRESIST", true},
+		{"Rain World target 300", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isName(tt.text); got != tt.want {
+			t.Errorf("isName(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCutTarget(t *testing.T) {
+	tests := []struct {
+		text       string
+		wantTerm   string
+		wantTarget []string
+	}{
+		{"Rain World", "Rain World", nil},
+		{"7 Days to Die", "7 Days to Die", nil},
+		{"Rain World target 300", "Rain World", []string{"target", "300"}},
+		{"Rain World, цель 299,99 50%", "Rain World", []string{"цель", "299,99", "50%"}},
+		{"Hitman 3 50%", "Hitman 3", []string{"50%"}},
+	}
+	for _, tt := range tests {
+		term, target := cutTarget(tt.text)
+		if term != tt.wantTerm || !reflect.DeepEqual(target, tt.wantTarget) {
+			t.Errorf("cutTarget(%q) = %q, %q, want %q, %q", tt.text, term, target, tt.wantTerm, tt.wantTarget)
+		}
+	}
+}
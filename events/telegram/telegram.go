@@ -1,29 +1,44 @@
 package telegram
 
 import (
+	"SteamSaleBot/clients/steam"
 	"SteamSaleBot/clients/telegram"
 	"SteamSaleBot/events"
 	"SteamSaleBot/lib/e"
+	"SteamSaleBot/lib/format"
 	"SteamSaleBot/storage"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"os"
-	"regexp"
-	"strconv"
 	"time"
 )
 
 type Processor struct {
 	tg      *telegram.Client
+	steam   Steam
 	offset  int
 	storage storage.Storage
+	dialogs *dialogs
+	router  *router
+	botName string
+}
+
+// Steam источник данных магазина, реализуется steam.Client.
+type Steam interface {
+	Game(gameID string) (steam.GameData, error)
+	Sale() ([]steam.GameInfo, error)
+	Prices(gameIDs []string) (map[string]steam.GamePrice, error)
+	Search(term string) ([]steam.SearchItem, error)
+	Apps(ref steam.Ref) ([]string, error)
 }
 
 type Meta struct {
-	ChatID   int
-	Username string
+	ChatID     int
+	UserID     int
+	Username   string
+	MessageID  int    // для нажатия кнопки - сообщение с клавиатурой
+	CallbackID string // заполнен только у events.Callback
 }
 
 type rawSale struct {
@@ -38,16 +53,27 @@ type Sale struct {
 	End   time.Time
 }
 
+// myGamesPageSize сколько игр показывать на одной странице /my_games.
+const myGamesPageSize = 5
+
+// parseMode разметка сообщений с подставленными данными, статичные тексты из messages.go в старом Markdown.
+const parseMode = format.MarkdownV2
+
 var (
 	ErrUnknownEventType = errors.New("unknown event type")
 	ErrUnknownMetaType  = errors.New("unknown meta type")
 )
 
-func New(client *telegram.Client, storage storage.Storage) *Processor {
-	return &Processor{
+// New создает процессор, dialogPath - файл для незаконченных диалогов, пустой - хранить только в памяти.
+func New(client *telegram.Client, steamClient Steam, storage storage.Storage, dialogPath string) *Processor {
+	p := &Processor{
 		tg:      client,
+		steam:   steamClient,
 		storage: storage,
+		dialogs: newDialogs(dialogPath, dialogTTL),
 	}
+	p.router = p.routes()
+	return p
 }
 
 func (p *Processor) Fetch(limit int) ([]events.Event, error) {
@@ -71,48 +97,77 @@ func (p *Processor) Fetch(limit int) ([]events.Event, error) {
 
 func (p *Processor) DiscNotif() {
 	for {
-		users, err := p.storage.Users()
+		users, err := p.activeUsers()
 		if err != nil {
 			log.Println("can't get users from storage", err)
 		}
+		prices := p.fetchPrices(users)
+
+		// минимумы берем до записи новых цен, чтобы сравнивать с уже виденными
+		lows := make(map[string]*storage.Price, len(prices))
+		for id := range prices {
+			low, err := p.storage.LowestPrice(id)
+			if err != nil && !errors.Is(err, storage.ErrNoPriceHistory) {
+				log.Println("can't get lowest price", err)
+			}
+			lows[id] = low
+		}
+
 		for u, games := range users {
-			time.Sleep(30 * time.Second)
-			msg := ""
+			var entries []string
 			for _, g := range games {
-				game, err := p.tg.Game(g.ID)
-				if err != nil {
-					log.Println("can't get game", err)
-					time.Sleep(5 * time.Minute)
-					game, err = p.tg.Game(g.ID)
-				}
-				re := regexp.MustCompile(`\d+`)
-				final, err := strconv.Atoi(re.FindString(game.Price.Final))
-				now, err := strconv.Atoi(re.FindString(g.Price))
-				if game.Price.Final == "" {
+				price, ok := prices[g.ID]
+				if !ok {
 					continue
 				}
-				u.Game.ID = g.ID
-				u.Game.Name = g.Name
-				u.Game.Price = game.Price.Final
+				p.recordPrice(g.ID, g.Name, price)
+				// у старых записей нет числовой цены, для них первое сравнение пропускаем
+				dropped := price.Initial > 0 && g.Final > 0 &&
+					g.Currency == price.Currency && price.Final < g.Final
+				u.Game = *g
+				u.Game.Price = price.FinalFormatted
+				u.Game.Final = price.Final
+				u.Game.Currency = price.Currency
+				// цель уведомляет один раз, пока условие выполняется
+				targetFired := false
+				if g.HasTarget() {
+					reached := price.Initial > 0 && g.TargetMet(price.Final, price.DiscountPercent)
+					targetFired = reached && !g.TargetReached
+					u.Game.TargetReached = reached
+				}
 				if err := p.storage.Save(u); err != nil {
 					log.Println("Ошибка сохранения DiscNotif: ", err)
 				}
-				if final < now {
-					msg += fmt.Sprintf("Скидка на игру %s: %s \n", g.Name, game.Price.Final)
+				// игра с целью уведомляет только о цели и не зависит от настройки скидок,
+				// иначе достигнутая при выключенной настройке цель потерялась бы
+				switch {
+				case targetFired:
+					entries = append(entries, newMsg().Textf("Цель достигнута для игры %s: %s", g.Name, price.FinalFormatted).Line().String())
+				case g.HasTarget():
+				case dropped && u.UserSettings.HistoricalLow && isHistoricalLow(price, lows[g.ID]):
+					entries = append(entries, newMsg().Textf("Исторический минимум на игру %s: %s", g.Name, price.FinalFormatted).Line().String())
+				case dropped && u.UserSettings.Discounts:
+					entries = append(entries, newMsg().Textf("Скидка на игру %s: %s", g.Name, price.FinalFormatted).Line().String())
 				}
 
 			}
-			if msg != "" && u.UserSettings.Discounts != false {
-				log.Println("Отправлено сообщение о скидке", u.UserName, msg)
-				if err := p.tg.SendMessage(u.UserSettings.ChatId, msg); err != nil {
-					log.Println("can't send message", err)
-				}
+			if len(entries) > 0 {
+				log.Println("Отправлено сообщение о скидке", u.UserName, entries)
+				p.notify(u, "", entries...)
 			}
 		}
 		time.Sleep(30 * time.Minute)
 	}
 }
 
+// isHistoricalLow сообщает, что цена опустилась до минимума, который видел бот, или ниже.
+func isHistoricalLow(price steam.GamePrice, low *storage.Price) bool {
+	if low == nil || price.Initial == 0 {
+		return false
+	}
+	return price.Final <= low.Final
+}
+
 func (p *Processor) WeekSaleNotif() {
 	for {
 		loc, _ := time.LoadLocation("Europe/Moscow")
@@ -121,13 +176,15 @@ func (p *Processor) WeekSaleNotif() {
 		if now.After(target) {
 			target = target.Add(24 * time.Hour)
 
-			users, err := p.storage.Users()
+			users, err := p.activeUsers()
 			if err != nil {
 				log.Println("can't get users from storage", err)
 			}
-			games, err := p.tg.Sale()
+			games, err := p.steam.Sale()
 			if err != nil {
+				// без списка скидок не шлем пустое сообщение, ждем следующий день
 				log.Println("can't get WeekSale", err)
+				users = nil
 			}
 			for u, _ := range users {
 				go p.weekSaleSend(games, u)
@@ -222,23 +279,21 @@ func (p *Processor) SalesNotif() {
 			var msg string
 			switch next.kind {
 			case "before-start":
-				msg = fmt.Sprintf("🟡 Завтра начнётся %s (%s МСК)", next.name, tMsk)
+				msg = newMsg().Textf("🟡 Завтра начнётся %s (%s МСК)", next.name, tMsk).String()
 			case "on-start":
-				msg = fmt.Sprintf("🟢 Началась %s! Идёт до %s (МСК)", next.name, next.end.In(loc).Format("02 Jan 15:04"))
+				msg = newMsg().Textf("🟢 Началась %s! Идёт до %s (МСК)", next.name, next.end.In(loc).Format("02 Jan 15:04")).String()
 			case "before-end":
-				msg = fmt.Sprintf("🔴 Завтра закончится %s (%s МСК)", next.name, tMsk)
+				msg = newMsg().Textf("🔴 Завтра закончится %s (%s МСК)", next.name, tMsk).String()
 			}
 
-			users, err := p.storage.Users()
+			users, err := p.activeUsers()
 			if err != nil {
 				log.Fatal("SalesNotif: can't load users:", err)
 			}
 			for u, _ := range users {
 				go func() {
 					log.Println("Отправлено сообщение о распродаже", u.UserName, u.UserSettings.ChatId)
-					if err := p.tg.SendMessage(u.UserSettings.ChatId, msg); err != nil {
-						log.Printf("SalesNotif: can't send to %d: %v", u.UserSettings.ChatId, err)
-					}
+					p.notify(u, msg)
 				}()
 			}
 
@@ -252,36 +307,93 @@ func (p *Processor) SalesNotif() {
 			future = remaining
 		}
 
-		if err := p.tg.SendMessage(2134561992, "SalesNotif: все уведомления отправлены, обновите распродажи и перезапустите бота"); err != nil {
+		if err := p.tg.SendMessage(adminID, "SalesNotif: все уведомления отправлены, обновите распродажи и перезапустите бота"); err != nil {
 			log.Printf("SalesNotif: can't send to admin")
 		}
 		time.Sleep(720 * time.Hour)
 	}
 }
 
+// activeUsers возвращает пользователей из хранилища без тех, кто заблокировал бота.
+func (p *Processor) activeUsers() (map[*storage.User][]*storage.Game, error) {
+	users, err := p.storage.Users()
+	if err != nil {
+		return nil, err
+	}
+	for u := range users {
+		if u.UserSettings.Inactive {
+			delete(users, u)
+		}
+	}
+	return users, nil
+}
+
+// notify отправляет уведомление и отключает пользователя, если бот заблокирован или чат удален.
+func (p *Processor) notify(u *storage.User, header string, entries ...string) {
+	err := p.sendList(u.UserSettings.ChatId, header, entries)
+	if err == nil {
+		return
+	}
+	if errors.Is(err, telegram.ErrBotBlocked) || errors.Is(err, telegram.ErrChatNotFound) {
+		log.Printf("user %d (%s) blocked the bot, deactivating: %v", u.ID, u.UserName, err)
+		if err := p.storage.SetActive(u.ID, false); err != nil {
+			log.Println("can't deactivate user", err)
+		}
+		return
+	}
+	log.Printf("can't send message to %d: %v", u.UserSettings.ChatId, err)
+}
+
+// sendList отправляет заголовок и записи списка, разбивая их на сообщения по лимиту Telegram.
+func (p *Processor) sendList(chatID int, header string, entries []string) error {
+	for _, msg := range telegram.Chunks(header, entries, telegram.MaxMessageLength) {
+		if err := p.sendMessage(chatID, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newMsg начинает сообщение с данными из Steam или от пользователя, они экранируются автоматически.
+func newMsg() *format.Builder {
+	return format.New(parseMode)
+}
+
+// sendMessage отправляет сообщение, собранное newMsg. Если Telegram все же не разобрал разметку,
+// повторяет его без форматирования.
+func (p *Processor) sendMessage(chatID int, text string) error {
+	err := p.tg.SendFormatted(chatID, text, parseMode)
+	if errors.Is(err, telegram.ErrBadMarkup) {
+		log.Println("bad markup, sending as plain text", err)
+		return p.tg.SendPlainMessage(chatID, format.Strip(parseMode, text))
+	}
+	return err
+}
+
 func (p *Processor) Process(event events.Event) error {
 	switch event.Type {
 	case events.Message:
 		return p.processMessage(event)
+	case events.Callback:
+		return p.processCallback(event)
 	default:
 		return e.Warp("can't process message", ErrUnknownEventType)
 
 	}
 }
 
-func (p *Processor) weekSaleSend(games []telegram.GameInfo, u *storage.User) {
-	msg := "Ежедневные скидки:"
+func (p *Processor) weekSaleSend(games []steam.GameInfo, u *storage.User) {
+	entries := make([]string, 0, len(games))
 	for _, g := range games {
-		msg += fmt.Sprintf("\n\nНазвание: "+g.Title+
-			"\nЦена до: "+g.OldPrice+
-			"\nЦена после: "+g.FinalPrice+
-			"\n[Открыть steam](%s)", g.URL)
+		entries = append(entries, newMsg().Line().Line().
+			Text("Название: "+g.Title).Line().
+			Text("Цена до: "+g.OldPrice).Line().
+			Text("Цена после: "+g.FinalPrice).Line().
+			Link("Открыть steam", g.URL).String())
 	}
-	if msg != "" && u.UserSettings.FreeWeekend != false {
+	if len(entries) > 0 && u.UserSettings.FreeWeekend != false {
 		log.Println("Отправлено сообщение о скидках недели", u.UserName, u.UserSettings.ChatId)
-		if err := p.tg.SendMessage(u.UserSettings.ChatId, msg); err != nil {
-			log.Println("can't send message", err)
-		}
+		p.notify(u, newMsg().Text("Ежедневные скидки:").String(), entries...)
 	}
 }
 
@@ -291,7 +403,7 @@ func (p *Processor) processMessage(event events.Event) error {
 		return e.Warp("can't process message", err)
 	}
 
-	if err := p.doCmd(event.Text, meta.ChatID, meta.Username); err != nil {
+	if err := p.doCmd(event.Text, meta); err != nil {
 		return e.Warp("can't process message", err)
 	}
 
@@ -314,25 +426,48 @@ func event(upd telegram.Update) events.Event {
 		Text: fetchText(upd),
 	}
 
-	if upd.Message != nil {
+	switch {
+	case upd.Message != nil:
 		res.Meta = Meta{
-			ChatID:   upd.Message.Chat.ID,
-			Username: upd.Message.From.Username,
+			ChatID:    upd.Message.Chat.ID,
+			UserID:    upd.Message.From.ID,
+			Username:  upd.Message.From.Username,
+			MessageID: upd.Message.MessageID,
+		}
+	case upd.CallbackQuery != nil:
+		cb := upd.CallbackQuery
+		meta := Meta{
+			ChatID:     cb.From.ID,
+			UserID:     cb.From.ID,
+			Username:   cb.From.Username,
+			CallbackID: cb.ID,
+		}
+		// у слишком старых сообщений Telegram не присылает message, тогда отвечаем в личный чат
+		if cb.Message != nil {
+			meta.ChatID = cb.Message.Chat.ID
+			meta.MessageID = cb.Message.MessageID
 		}
+		res.Meta = meta
 	}
 	return res
 }
 
 func fetchText(upd telegram.Update) string {
-	if upd.Message != nil {
+	switch {
+	case upd.Message != nil:
 		return upd.Message.Text
+	case upd.CallbackQuery != nil:
+		return upd.CallbackQuery.Data
 	}
 	return ""
 }
 
 func fetchType(upd telegram.Update) events.Type {
-	if upd.Message != nil {
+	switch {
+	case upd.Message != nil:
 		return events.Message
+	case upd.CallbackQuery != nil:
+		return events.Callback
 	}
-	return events.Message
+	return events.Unknown
 }
@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"SteamSaleBot/clients/telegram"
+	"SteamSaleBot/events"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// webhookWait сколько Fetch ждет первое обновление, как long polling в getUpdates.
+const webhookWait = 30 * time.Second
+
+// Webhook принимает обновления от Telegram по HTTP и отдает их тем же потоком событий, что и Processor.Fetch,
+// поэтому подключается к event_consumer.Consumer вместо процессора как events.Fetcher.
+type Webhook struct {
+	secret  string
+	updates chan telegram.Update
+}
+
+func NewWebhook(secret string, buffer int) *Webhook {
+	return &Webhook{
+		secret:  secret,
+		updates: make(chan telegram.Update, buffer),
+	}
+}
+
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	got := r.Header.Get(telegram.SecretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(w.secret)) != 1 {
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var upd telegram.Update
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		log.Println("can't decode webhook update", err)
+		// повтор того же тела не поможет, поэтому отвечаем 200 и пропускаем обновление
+		rw.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// если обработчик не успевает, держим запрос: Telegram не пришлет следующие, пока не получит ответ
+	select {
+	case w.updates <- upd:
+		rw.WriteHeader(http.StatusOK)
+	case <-r.Context().Done():
+	}
+}
+
+func (w *Webhook) Fetch(limit int) ([]events.Event, error) {
+	var res []events.Event
+
+	timer := time.NewTimer(webhookWait)
+	defer timer.Stop()
+	select {
+	case upd := <-w.updates:
+		res = append(res, event(upd))
+	case <-timer.C:
+		return nil, nil
+	}
+
+	for len(res) < limit {
+		select {
+		case upd := <-w.updates:
+			res = append(res, event(upd))
+		default:
+			return res, nil
+		}
+	}
+	return res, nil
+}
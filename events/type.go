@@ -16,6 +16,7 @@ type Type int
 const (
 	Unknown Type = iota
 	Message
+	Callback
 )
 
 type Event struct {
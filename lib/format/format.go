@@ -0,0 +1,178 @@
+package format
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Mode значение parse_mode для Bot API.
+type Mode string
+
+const (
+	MarkdownV2 Mode = "MarkdownV2"
+	HTML       Mode = "HTML"
+)
+
+// Builder собирает сообщение в MarkdownV2 или HTML. Весь переданный текст экранируется,
+// разметку добавляют только методы Bold, Italic, Code и Link.
+type Builder struct {
+	mode Mode
+	b    strings.Builder
+}
+
+func New(mode Mode) *Builder {
+	return &Builder{mode: mode}
+}
+
+func (b *Builder) Mode() Mode {
+	return b.mode
+}
+
+func (b *Builder) String() string {
+	return b.b.String()
+}
+
+func (b *Builder) Text(s string) *Builder {
+	b.b.WriteString(Escape(b.mode, s))
+	return b
+}
+
+func (b *Builder) Textf(format string, args ...any) *Builder {
+	return b.Text(fmt.Sprintf(format, args...))
+}
+
+func (b *Builder) Line() *Builder {
+	b.b.WriteString("\n")
+	return b
+}
+
+func (b *Builder) Bold(s string) *Builder {
+	if b.mode == HTML {
+		return b.wrap("<b>", Escape(b.mode, s), "</b>")
+	}
+	return b.wrap("*", Escape(b.mode, s), "*")
+}
+
+func (b *Builder) Italic(s string) *Builder {
+	if b.mode == HTML {
+		return b.wrap("<i>", Escape(b.mode, s), "</i>")
+	}
+	return b.wrap("_", Escape(b.mode, s), "_")
+}
+
+func (b *Builder) Code(s string) *Builder {
+	if b.mode == HTML {
+		return b.wrap("<code>", Escape(b.mode, s), "</code>")
+	}
+	return b.wrap("`", escapeWith(s, "`\\"), "`")
+}
+
+func (b *Builder) Link(text, url string) *Builder {
+	if b.mode == HTML {
+		return b.wrap(`<a href="`+Escape(b.mode, url)+`">`, Escape(b.mode, text), "</a>")
+	}
+	return b.wrap("[", Escape(b.mode, text), "]("+escapeWith(url, `)\`)+")")
+}
+
+func (b *Builder) wrap(open, s, close string) *Builder {
+	b.b.WriteString(open)
+	b.b.WriteString(s)
+	b.b.WriteString(close)
+	return b
+}
+
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+var htmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// Escape экранирует обычный текст для выбранного режима.
+func Escape(mode Mode, s string) string {
+	if mode == HTML {
+		return htmlReplacer.Replace(s)
+	}
+	return escapeWith(s, markdownV2Special)
+}
+
+func escapeWith(s string, special string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Strip убирает из собранного сообщения разметку и экранирование, чтобы отправить его без parse_mode.
+// Ссылка превращается в "текст (url)".
+func Strip(mode Mode, s string) string {
+	if mode == HTML {
+		return stripHTML(s)
+	}
+
+	var (
+		b       strings.Builder
+		escaped bool
+		code    bool
+		url     bool
+	)
+	b.Grow(len(s))
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+			b.WriteRune(r)
+		case r == '\\':
+			escaped = true
+		case r == '`':
+			code = !code
+		case code:
+			b.WriteRune(r)
+		case url:
+			if r == ')' {
+				url = false
+			}
+			b.WriteRune(r)
+		case r == ']' && strings.HasPrefix(s[i+1:], "("):
+			url = true
+			b.WriteByte(' ')
+		case strings.ContainsRune("*_~|[]", r):
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func stripHTML(s string) string {
+	var (
+		b    strings.Builder
+		tag  strings.Builder
+		in   bool
+		href string
+	)
+	for _, r := range s {
+		switch {
+		case r == '<':
+			in = true
+			tag.Reset()
+		case r == '>' && in:
+			in = false
+			t := tag.String()
+			if u, ok := strings.CutPrefix(t, `a href="`); ok {
+				href = strings.TrimSuffix(u, `"`)
+			} else if t == "/a" && href != "" {
+				b.WriteString(" (" + href + ")")
+				href = ""
+			}
+		case in:
+			tag.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(b.String())
+}
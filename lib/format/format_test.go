@@ -0,0 +1,27 @@
+package format
+
+import "testing"
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(b *Builder) *Builder
+		want  string
+	}{
+		{"text", func(b *Builder) *Builder { return b.Text("Half_Life 2: *Ep.1* (2006)!") }, "Half_Life 2: *Ep.1* (2006)!"},
+		{"bold", func(b *Builder) *Builder { return b.Bold("Цена:").Text(" 1 299 руб.") }, "Цена: 1 299 руб."},
+		{"italic", func(b *Builder) *Builder { return b.Italic("a-b") }, "a-b"},
+		{"code", func(b *Builder) *Builder { return b.Code("1_2`3") }, "1_2`3"},
+		{"link", func(b *Builder) *Builder { return b.Link("Открыть [x]", "https://s.com/app/1_(a)") }, "Открыть [x] (https://s.com/app/1_(a))"},
+	}
+	for _, tt := range tests {
+		for _, mode := range []Mode{MarkdownV2, HTML} {
+			t.Run(tt.name+"/"+string(mode), func(t *testing.T) {
+				got := Strip(mode, tt.build(New(mode)).String())
+				if got != tt.want {
+					t.Errorf("Strip() = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
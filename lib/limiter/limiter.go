@@ -0,0 +1,69 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter token bucket, который можно разделить между несколькими горутинами.
+type Limiter struct {
+	mu     sync.Mutex
+	tokens float64
+	burst  float64
+	rate   float64 // токенов в секунду
+	last   time.Time
+	until  time.Time // до этого момента запросы не отправляем
+}
+
+func New(perSecond float64, burst int) *Limiter {
+	if perSecond <= 0 {
+		perSecond = 1
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &Limiter{
+		tokens: float64(burst),
+		burst:  float64(burst),
+		rate:   perSecond,
+		last:   time.Now(),
+	}
+}
+
+// Wait блокирует, пока не появится токен.
+func (l *Limiter) Wait() {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if now.Before(l.until) {
+			wait := l.until.Sub(now)
+			l.mu.Unlock()
+			time.Sleep(wait)
+			continue
+		}
+
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return
+		}
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// Pause останавливает всех ожидающих на d, например после ответа 429.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(l.until) {
+		l.until = until
+	}
+	// токены копятся только после паузы, иначе по ее окончании сразу уйдет вся пачка
+	l.tokens = 0
+	l.last = l.until
+}
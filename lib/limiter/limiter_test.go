@@ -0,0 +1,37 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitBurst(t *testing.T) {
+	l := New(10, 3)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		l.Wait()
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Fatalf("burst of 3 took %v", d)
+	}
+
+	l.Wait()
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Fatalf("4th token came after %v, want about 100ms", d)
+	}
+}
+
+func TestPauseDoesNotRefill(t *testing.T) {
+	l := New(10, 3)
+	pause := 200 * time.Millisecond
+	start := time.Now()
+	l.Pause(pause)
+
+	for i := 0; i < 3; i++ {
+		l.Wait()
+	}
+	// после паузы токены идут по одному: 3 токена при 10 в секунду - еще около 300ms
+	if d := time.Since(start); d < pause+250*time.Millisecond {
+		t.Fatalf("3 waits after %v pause took %v, tokens refilled during pause", pause, d)
+	}
+}
@@ -1,45 +1,148 @@
 package main
 
 import (
+	"SteamSaleBot/clients/steam"
 	tgClient "SteamSaleBot/clients/telegram"
 	event_consumer "SteamSaleBot/consumer/event-consumer"
+	"SteamSaleBot/events"
 	"SteamSaleBot/events/telegram"
+	"SteamSaleBot/storage"
 	"SteamSaleBot/storage/files"
+	"SteamSaleBot/storage/sqlite"
 	"flag"
-	"time"
 	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
 )
 
 const (
 	tgBotHost   = "api.telegram.org"
+	steamURL    = "https://store.steampowered.com"
 	storagePath = "storage/db"
+	sqlitePath  = "storage/bot.db"
+	cachePath   = "storage/steam-cache"
+	dialogsPath = "storage/dialogs"
 	bathSize    = 100
 )
 
+var (
+	token       = flag.String("token", "", "The token to use")
+	storageType = flag.String("storage", "files", "Storage backend: files or sqlite")
+	steamHost   = flag.String("steam-url", steamURL, "Steam store base URL")
+	steamRPM    = flag.Int("steam-rpm", 40, "Steam store requests per minute shared by all lookups")
+
+	gameTTL   = flag.Duration("steam-game-ttl", time.Hour, "How long to cache appdetails for /check and /add")
+	priceTTL  = flag.Duration("steam-price-ttl", 10*time.Minute, "How long to cache batched price lookups")
+	saleTTL   = flag.Duration("steam-sale-ttl", time.Hour, "How long to cache the weeklong deals page")
+	staleTTL  = flag.Duration("steam-stale", 10*time.Minute, "How long to serve expired cache entries while refreshing them")
+	cacheFile = flag.String("steam-cache", cachePath, "File to persist the Steam cache, empty to keep it in memory only")
+
+	webhookURL    = flag.String("webhook-url", "", "Public HTTPS URL for Telegram webhook, empty to use long polling")
+	webhookAddr   = flag.String("webhook-addr", ":8080", "Address for the webhook HTTP server to listen on")
+	webhookSecret = flag.String("webhook-secret", "", "Secret token Telegram sends with every webhook request")
+)
+
 func main() {
 loc, err := time.LoadLocation("Asia/Yekaterinburg") // или твой часовой пояс
   if err != nil {
     panic(err)
   }
   time.Local = loc
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	flag.Parse()
+
+	tg := tgClient.New(tgBotHost, mustToken())
 	eventsProcessor := telegram.New(
-		tgClient.New(tgBotHost, mustToken()),
-		files.New(storagePath),
+		tg,
+		steam.NewCache(steam.New(*steamHost, *steamRPM), steam.CacheConfig{
+			GameTTL:  *gameTTL,
+			PriceTTL: *priceTTL,
+			SaleTTL:  *saleTTL,
+			Stale:    *staleTTL,
+			Path:     *cacheFile,
+		}),
+		mustStorage(*storageType),
+		dialogsPath,
 	)
 	log.Println("Starting telegram bot")
 
-	consumer := event_consumer.New(eventsProcessor, eventsProcessor, bathSize)
+	consumer := event_consumer.New(mustFetcher(tg, eventsProcessor), eventsProcessor, bathSize)
 	if err := consumer.Start(); err != nil {
 		log.Fatal(err)
 	}
 }
 
 func mustToken() string {
-	token := flag.String("token", "", "The token to use")
-	flag.Parse()
-
 	if *token == "" {
 		log.Fatal("You must provide a token")
 	}
 	return *token
 }
+
+// mustFetcher выбирает источник обновлений: вебхук, если задан -webhook-url, иначе long polling процессора.
+func mustFetcher(tg *tgClient.Client, processor *telegram.Processor) events.Fetcher {
+	if *webhookURL == "" {
+		// getUpdates не работает, пока у бота остался вебхук с прошлого запуска
+		if err := tg.DeleteWebhook(); err != nil {
+			log.Println("can't delete webhook", err)
+		}
+		return processor
+	}
+
+	if *webhookSecret == "" {
+		log.Fatal("You must provide a webhook secret")
+	}
+	u, err := url.Parse(*webhookURL)
+	if err != nil {
+		log.Fatal("bad webhook url: ", err)
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	webhook := telegram.NewWebhook(*webhookSecret, bathSize)
+	mux := http.NewServeMux()
+	mux.Handle(path, webhook)
+	go func() {
+		log.Println("Starting webhook server on", *webhookAddr)
+		log.Fatal(http.ListenAndServe(*webhookAddr, mux))
+	}()
+
+	if err := tg.SetWebhook(*webhookURL, *webhookSecret); err != nil {
+		log.Fatal(err)
+	}
+	return webhook
+}
+
+func mustStorage(kind string) storage.Storage {
+	switch kind {
+	case "files":
+		s := files.New(storagePath)
+		if err := s.Migrate(); err != nil {
+			log.Fatal(err)
+		}
+		return s
+	case "sqlite":
+		s, err := sqlite.New(sqlitePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := s.Init(); err != nil {
+			log.Fatal(err)
+		}
+		return s
+	default:
+		log.Fatalf("unknown storage %q", kind)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"SteamSaleBot/lib/e"
+	"SteamSaleBot/storage"
+	"SteamSaleBot/storage/files"
+	"SteamSaleBot/storage/sqlite"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+type jsonUser struct {
+	ID            int        `json:"id"`
+	UserName      string     `json:"user_name"`
+	ChatID        int        `json:"chat_id"`
+	Sales         bool       `json:"sales"`
+	FreeWeekend   bool       `json:"free_weekend"`
+	Discounts     bool       `json:"discounts"`
+	HistoricalLow bool       `json:"historical_low"`
+	Inactive      bool       `json:"inactive,omitempty"`
+	Games         []jsonGame `json:"games"`
+}
+
+type jsonGame struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Price          string `json:"price"`
+	Final          int    `json:"final"`
+	Currency       string `json:"currency"`
+	TargetPrice    int    `json:"target_price,omitempty"`
+	TargetDiscount int    `json:"target_discount,omitempty"`
+	TargetReached  bool   `json:"target_reached,omitempty"`
+}
+
+// runMigrate переносит пользователей и историю цен из gob файлов storage/db в sqlite:
+//
+//	SteamSaleBot migrate -out storage/bot.db
+//	SteamSaleBot migrate -dry-run
+//
+// С -export вместо переноса пишет пользователей и игры в JSON файл. Это выгрузка для просмотра
+// и сторонних скриптов, бот ее не читает и запускаться с ней как с хранилищем не может.
+func runMigrate(args []string) (err error) {
+	defer func() { err = e.WrapIfErr("can't migrate", err) }()
+
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", storagePath, "Path to gob files storage")
+	out := fs.String("out", sqlitePath, "Path to target sqlite database")
+	export := fs.String("export", "", "Write users and games to this JSON file instead of migrating")
+	dryRun := fs.Bool("dry-run", false, "Only print what would be migrated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	src := files.New(*from)
+	users, report, err := src.Scan()
+	if err != nil {
+		return err
+	}
+	printReport(report)
+
+	if *dryRun {
+		return nil
+	}
+
+	if *export != "" {
+		if err := writeJSON(*export, users); err != nil {
+			return err
+		}
+		log.Printf("exported %d users and %d games to %s", report.Users, report.Games, *export)
+		return nil
+	}
+
+	s, err := sqlite.New(*out)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = s.Close() }()
+	if err := s.Init(); err != nil {
+		return err
+	}
+	if err := copyUsers(s, users); err != nil {
+		return err
+	}
+	if err := copyPrices(s, src); err != nil {
+		return err
+	}
+
+	log.Printf("migrated %d users, %d games and %d prices to %s", report.Users, report.Games, report.Prices, *out)
+	return nil
+}
+
+func printReport(r *files.ScanReport) {
+	fmt.Printf("Пользователей: %d\n", r.Users)
+	fmt.Printf("Игр: %d\n", r.Games)
+	fmt.Printf("История цен: %d записей по %d приложениям\n", r.Prices, r.PriceApps)
+
+	fmt.Printf("Ошибок декодирования: %d\n", len(r.Failed))
+	for _, f := range r.Failed {
+		fmt.Println("  ", f)
+	}
+
+	fmt.Printf("Каталогов без username: %d\n", len(r.EmptyNames))
+	for _, d := range r.EmptyNames {
+		fmt.Println("  ", d)
+	}
+
+	fmt.Printf("Пропущено каталогов без id: %d\n", len(r.NoID))
+	for _, d := range r.NoID {
+		fmt.Println("  ", d)
+	}
+}
+
+func copyUsers(target storage.Storage, users map[*storage.User][]*storage.Game) error {
+	for u, games := range users {
+		if u.ID == 0 {
+			log.Printf("skip user %q: no id", u.UserName)
+			continue
+		}
+
+		if err := target.CreateSettings(&storage.User{
+			ID:           u.ID,
+			UserName:     u.UserName,
+			UserSettings: storage.UserSettings{ChatId: u.UserSettings.ChatId},
+		}); err != nil {
+			return err
+		}
+
+		// в интерфейсе настройки можно только переключать, поэтому переключаем те, что отличаются
+		cur, err := target.Settings(u.ID)
+		if err != nil {
+			return err
+		}
+		var change []string
+		if cur.UserSettings.Sales != u.UserSettings.Sales {
+			change = append(change, "1")
+		}
+		if cur.UserSettings.FreeWeekend != u.UserSettings.FreeWeekend {
+			change = append(change, "2")
+		}
+		if cur.UserSettings.Discounts != u.UserSettings.Discounts {
+			change = append(change, "3")
+		}
+		if cur.UserSettings.HistoricalLow != u.UserSettings.HistoricalLow {
+			change = append(change, "4")
+		}
+		if len(change) > 0 {
+			if err := target.UpdSettings(u.ID, change); err != nil {
+				return err
+			}
+		}
+		if err := target.SetActive(u.ID, !u.UserSettings.Inactive); err != nil {
+			return err
+		}
+
+		for _, g := range games {
+			if err := target.Save(&storage.User{ID: u.ID, UserName: u.UserName, Game: *g}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyPrices переносит историю цен, от нее зависят /history и уведомления об историческом минимуме.
+// Приложения, у которых в target история уже есть, пропускаются, чтобы повторный запуск не дублировал записи.
+func copyPrices(target storage.Storage, src *files.Storage) error {
+	apps, err := src.PriceApps()
+	if err != nil {
+		return err
+	}
+	for _, appID := range apps {
+		if _, err := target.Prices(appID, 1); !errors.Is(err, storage.ErrNoPriceHistory) {
+			if err != nil {
+				return err
+			}
+			log.Printf("skip prices of %s: already migrated", appID)
+			continue
+		}
+
+		prices, err := src.Prices(appID, 0)
+		if err != nil {
+			// битый файл уже есть в отчете Scan
+			log.Printf("skip prices of %s: %v", appID, err)
+			continue
+		}
+		// Prices отдает от новых к старым, пишем в исходном порядке
+		for i := len(prices) - 1; i >= 0; i-- {
+			if err := target.AddPrice(prices[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeJSON выгрузка для -export, историю цен не включает.
+func writeJSON(path string, users map[*storage.User][]*storage.Game) error {
+	res := make([]jsonUser, 0, len(users))
+	for u, games := range users {
+		ju := jsonUser{
+			ID:            u.ID,
+			UserName:      u.UserName,
+			ChatID:        u.UserSettings.ChatId,
+			Sales:         u.UserSettings.Sales,
+			FreeWeekend:   u.UserSettings.FreeWeekend,
+			Discounts:     u.UserSettings.Discounts,
+			HistoricalLow: u.UserSettings.HistoricalLow,
+			Inactive:      u.UserSettings.Inactive,
+			Games:         make([]jsonGame, 0, len(games)),
+		}
+		for _, g := range games {
+			ju.Games = append(ju.Games, jsonGame{
+				ID:             g.ID,
+				Name:           g.Name,
+				Price:          g.Price,
+				Final:          g.Final,
+				Currency:       g.Currency,
+				TargetPrice:    g.TargetPrice,
+				TargetDiscount: g.TargetDiscount,
+				TargetReached:  g.TargetReached,
+			})
+		}
+		res = append(res, ju)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
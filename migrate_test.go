@@ -0,0 +1,60 @@
+package main
+
+import (
+	"SteamSaleBot/storage"
+	"SteamSaleBot/storage/files"
+	"SteamSaleBot/storage/sqlite"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyPrices(t *testing.T) {
+	src := files.New(t.TempDir())
+	start := time.Unix(1700000000, 0)
+	for i, final := range []int{50000, 30000, 40000} {
+		p := &storage.Price{AppID: "312520", Name: "Rain World", Initial: 50000, Final: final, Time: start.Add(time.Duration(i) * time.Hour)}
+		if err := src.AddPrice(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, report, err := src.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.PriceApps != 1 || report.Prices != 3 {
+		t.Fatalf("report prices = %d apps, %d records, want 1, 3", report.PriceApps, report.Prices)
+	}
+
+	target, err := sqlite.New(filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = target.Close() }()
+	if err := target.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// повторный запуск не должен дублировать историю
+	for i := 0; i < 2; i++ {
+		if err := copyPrices(target, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prices, err := target.Prices("312520", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 3 || prices[0].Final != 40000 || prices[2].Final != 50000 {
+		t.Fatalf("prices after migration = %+v", prices)
+	}
+	low, err := target.LowestPrice("312520")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low.Final != 30000 {
+		t.Fatalf("lowest price = %d, want 30000", low.Final)
+	}
+}
@@ -7,24 +7,30 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
+	"sync"
 )
 
 type Storage struct {
 	basePath string
+	mu       *sync.Mutex // защищает файлы истории цен
 }
 
-const defaultPerm = 0774
+const (
+	defaultPerm = 0774
+	pricesDir   = "prices"
+)
 
 func New(basePath string) *Storage {
-	return &Storage{basePath: basePath}
+	return &Storage{basePath: basePath, mu: &sync.Mutex{}}
 }
 
 func (s Storage) Save(u *storage.User) (err error) {
 	defer func() { err = e.WrapIfErr("can't save game", err) }()
-	fPath := filepath.Join(s.basePath, u.UserName, "games")
+	fPath := filepath.Join(s.userPath(u.ID), "games")
 
 	if err := os.MkdirAll(fPath, defaultPerm); err != nil {
 		return err
@@ -41,17 +47,16 @@ func (s Storage) Save(u *storage.User) (err error) {
 		return err
 	}
 	defer func() { _ = file.Close }()
-	g := storage.Game{Name: u.Game.Name, ID: u.Game.ID, Price: u.Game.Price}
-	if err = gob.NewEncoder(file).Encode(g); err != nil {
+	if err = gob.NewEncoder(file).Encode(u.Game); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s Storage) CheckAllGame(userName string) (Game []*storage.Game, err error) {
+func (s Storage) CheckAllGame(userID int) (Game []*storage.Game, err error) {
 	defer func() { err = e.WrapIfErr("can't check games", err) }()
 
-	fPath := filepath.Join(s.basePath, userName, "games")
+	fPath := filepath.Join(s.userPath(userID), "games")
 	files, err := ioutil.ReadDir(fPath)
 	if err != nil {
 		return nil, err
@@ -77,7 +82,7 @@ func (s Storage) Remove(u *storage.User) error {
 		return e.Warp("can't remove file", err)
 	}
 
-	path := filepath.Join(s.basePath, u.UserName, "games", fileName)
+	path := filepath.Join(s.userPath(u.ID), "games", fileName)
 
 	switch _, err = os.Stat(path); {
 	case errors.Is(err, os.ErrNotExist):
@@ -98,14 +103,24 @@ func (s Storage) Remove(u *storage.User) error {
 
 func (s Storage) CreateSettings(u *storage.User) (err error) {
 	defer func() { err = e.WrapIfErr("can't save game", err) }()
-	fPath := filepath.Join(s.basePath, u.UserName)
+	fPath := s.userPath(u.ID)
 
 	if err := os.MkdirAll(fPath, defaultPerm); err != nil {
 		return err
 	}
 	fPath = filepath.Join(fPath, "settings")
 	if _, err := os.Stat(fPath); err == nil {
-		return nil
+		// настройки уже есть, обновляем только отображаемое имя и чат
+		user, err := s.decodeSettings(fPath)
+		if err != nil {
+			return err
+		}
+		if user.UserName == u.UserName && user.UserSettings.ChatId == u.UserSettings.ChatId {
+			return nil
+		}
+		user.UserName = u.UserName
+		user.UserSettings.ChatId = u.UserSettings.ChatId
+		return s.encodeSettings(fPath, user)
 	}
 	file, err := os.Create(fPath)
 	if err != nil {
@@ -131,6 +146,9 @@ func (s Storage) Users() (usersFile map[*storage.User][]*storage.Game, err error
 	users, err := ioutil.ReadDir(fPath)
 
 	for _, user := range users {
+		if !user.IsDir() || user.Name() == pricesDir {
+			continue
+		}
 		os.MkdirAll(filepath.Join(fPath, user.Name(), "games"), defaultPerm)
 		files, err := ioutil.ReadDir(filepath.Join(fPath, user.Name(), "games"))
 		if err != nil {
@@ -141,6 +159,9 @@ func (s Storage) Users() (usersFile map[*storage.User][]*storage.Game, err error
 		if err != nil {
 			return nil, err
 		}
+		if userSet.ID == 0 {
+			userSet.ID, _ = strconv.Atoi(user.Name())
+		}
 
 		games := make([]*storage.Game, 0)
 		for _, file := range files {
@@ -157,51 +178,137 @@ func (s Storage) Users() (usersFile map[*storage.User][]*storage.Game, err error
 	return usersFile, nil
 }
 
-func (s Storage) UpdSettings(userName string, change []string) (err error) {
-	defer func() { err = e.WrapIfErr("can't upd settings", err) }()
+// ScanReport описывает содержимое хранилища, прочитанное через Scan.
+type ScanReport struct {
+	Users      int
+	Games      int
+	Failed     []string // файлы, которые не удалось декодировать
+	EmptyNames []string // каталоги пользователей без username
+	NoID       []string // каталоги, для которых не удалось определить id, в Users и Games не входят
+	PriceApps  int      // приложений с историей цен
+	Prices     int      // записей истории цен
+}
 
-	fPath := filepath.Join(s.basePath, userName, "settings")
-	user, err := s.decodeSettings(fPath)
+// Scan читает всех пользователей как Users, но не останавливается на битых файлах
+// и ничего не меняет на диске, поэтому подходит для миграции и dry-run отчета.
+func (s Storage) Scan() (usersFile map[*storage.User][]*storage.Game, report *ScanReport, err error) {
+	defer func() { err = e.WrapIfErr("can't scan storage", err) }()
+
+	usersFile = make(map[*storage.User][]*storage.Game)
+	report = &ScanReport{}
+
+	dirs, err := os.ReadDir(s.basePath)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	for _, i := range change {
-		i = strings.TrimSpace(i)
-		switch i {
-		case "1":
-			if user.UserSettings.Sales {
-				user.UserSettings.Sales = false
-				continue
-			}
-			user.UserSettings.Sales = true
-		case "2":
-			if user.UserSettings.FreeWeekend {
-				user.UserSettings.FreeWeekend = false
-				continue
+
+	_, err = os.Stat(filepath.Join(s.basePath, "settings"))
+	noNameUser := err == nil
+
+	userDirs := make([]string, 0, len(dirs)+1)
+	for _, dir := range dirs {
+		if !dir.IsDir() || dir.Name() == pricesDir || (noNameUser && dir.Name() == "games") {
+			continue
+		}
+		userDirs = append(userDirs, dir.Name())
+	}
+	if noNameUser {
+		userDirs = append(userDirs, "")
+	}
+
+	for _, dirName := range userDirs {
+		uPath := filepath.Join(s.basePath, dirName)
+
+		user, err := s.decodeSettings(filepath.Join(uPath, "settings"))
+		if err != nil {
+			report.Failed = append(report.Failed, filepath.Join(uPath, "settings"))
+			continue
+		}
+		if user.ID == 0 {
+			if id, err := strconv.Atoi(dirName); err == nil {
+				user.ID = id
+			} else {
+				// старый каталог по username, id чата совпадает с id пользователя
+				user.ID = user.UserSettings.ChatId
+				user.UserName = dirName
 			}
-			user.UserSettings.FreeWeekend = true
-		case "3":
-			if user.UserSettings.Discounts {
-				user.UserSettings.Discounts = false
+		}
+		if user.ID == 0 {
+			// мигрировать такого пользователя не к чему привязать
+			report.NoID = append(report.NoID, uPath)
+			continue
+		}
+		if user.UserName == "" {
+			report.EmptyNames = append(report.EmptyNames, uPath)
+		}
+
+		files, err := os.ReadDir(filepath.Join(uPath, "games"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, err
+		}
+
+		games := make([]*storage.Game, 0, len(files))
+		for _, file := range files {
+			gPath := filepath.Join(uPath, "games", file.Name())
+			game, err := s.decodeGame(gPath)
+			if err != nil {
+				report.Failed = append(report.Failed, gPath)
 				continue
 			}
-			user.UserSettings.Discounts = true
+			games = append(games, game)
 		}
+
+		usersFile[user] = games
+		report.Users++
+		report.Games += len(games)
 	}
-	file, err := os.Create(fPath)
+
+	apps, err := s.PriceApps()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer func() { _ = file.Close }()
-	if err = gob.NewEncoder(file).Encode(user); err != nil {
+	for _, appID := range apps {
+		prices, err := s.Prices(appID, 0)
+		if err != nil {
+			report.Failed = append(report.Failed, filepath.Join(s.basePath, pricesDir, appID))
+			continue
+		}
+		report.PriceApps++
+		report.Prices += len(prices)
+	}
+
+	return usersFile, report, nil
+}
+
+func (s Storage) UpdSettings(userID int, change []string) (err error) {
+	defer func() { err = e.WrapIfErr("can't upd settings", err) }()
+
+	fPath := filepath.Join(s.userPath(userID), "settings")
+	user, err := s.decodeSettings(fPath)
+	if err != nil {
 		return err
 	}
+	user.UserSettings.Toggle(change)
+	return s.encodeSettings(fPath, user)
+}
 
-	return nil
+func (s Storage) SetActive(userID int, active bool) (err error) {
+	defer func() { err = e.WrapIfErr("can't set active", err) }()
+
+	fPath := filepath.Join(s.userPath(userID), "settings")
+	user, err := s.decodeSettings(fPath)
+	if err != nil {
+		return err
+	}
+	if user.UserSettings.Inactive == !active {
+		return nil
+	}
+	user.UserSettings.Inactive = !active
+	return s.encodeSettings(fPath, user)
 }
 
-func (s Storage) Settings(userName string) (*storage.User, error) {
-	fPath := filepath.Join(s.basePath, userName)
+func (s Storage) Settings(userID int) (*storage.User, error) {
+	fPath := s.userPath(userID)
 	set, err := s.decodeSettings(filepath.Join(fPath, "settings"))
 
 	if err != nil {
@@ -237,6 +344,110 @@ func (s Storage) decodeSettings(filePath string) (*storage.User, error) {
 	return &p, nil
 }
 
+func (s Storage) encodeSettings(filePath string, u *storage.User) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return e.Warp("can't create settings", err)
+	}
+	defer func() { _ = file.Close() }()
+	if err := gob.NewEncoder(file).Encode(u); err != nil {
+		return e.Warp("can't encode settings", err)
+	}
+	return nil
+}
+
+// Migrate переносит старые каталоги storage/db/<username> в storage/db/<user id>.
+// В личном чате id чата совпадает с id пользователя, поэтому id берется из сохраненного ChatId.
+func (s Storage) Migrate() (err error) {
+	defer func() { err = e.WrapIfErr("can't migrate storage", err) }()
+
+	dirs, err := os.ReadDir(s.basePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// пользователи без username сохранялись прямо в корень хранилища
+	_, err = os.Stat(filepath.Join(s.basePath, "settings"))
+	noNameUser := err == nil
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		if _, err := strconv.Atoi(dir.Name()); err == nil {
+			continue
+		}
+		if dir.Name() == pricesDir || (noNameUser && dir.Name() == "games") {
+			continue
+		}
+		if err := s.migrateUser(dir.Name()); err != nil {
+			log.Printf("can't migrate user %s: %v", dir.Name(), err)
+		}
+	}
+
+	if noNameUser {
+		if err := s.migrateUser(""); err != nil {
+			log.Printf("can't migrate user without username: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s Storage) migrateUser(dirName string) error {
+	oldPath := filepath.Join(s.basePath, dirName)
+
+	user, err := s.decodeSettings(filepath.Join(oldPath, "settings"))
+	if err != nil {
+		return err
+	}
+	if user.UserSettings.ChatId == 0 {
+		return errors.New("settings have no chat id")
+	}
+	user.ID = user.UserSettings.ChatId
+	user.UserName = dirName
+
+	newPath := s.userPath(user.ID)
+	if err := os.MkdirAll(filepath.Join(newPath, "games"), defaultPerm); err != nil {
+		return err
+	}
+
+	newSettings := filepath.Join(newPath, "settings")
+	if _, err := os.Stat(newSettings); errors.Is(err, os.ErrNotExist) {
+		if err := s.encodeSettings(newSettings, user); err != nil {
+			return err
+		}
+	}
+
+	games, err := os.ReadDir(filepath.Join(oldPath, "games"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, file := range games {
+		game, err := s.decodeGame(filepath.Join(oldPath, "games", file.Name()))
+		if err != nil {
+			return err
+		}
+		if err := s.Save(&storage.User{ID: user.ID, UserName: user.UserName, Game: *game}); err != nil {
+			return err
+		}
+	}
+
+	if dirName == "" {
+		_ = os.Remove(filepath.Join(oldPath, "settings"))
+		return os.RemoveAll(filepath.Join(oldPath, "games"))
+	}
+	log.Printf("migrated user %s to %d", dirName, user.ID)
+	return os.RemoveAll(oldPath)
+}
+
+func (s Storage) userPath(userID int) string {
+	return filepath.Join(s.basePath, strconv.Itoa(userID))
+}
+
 func fileName(p *storage.User) (string, error) {
 	return p.Hash()
 }
@@ -0,0 +1,105 @@
+package files
+
+import (
+	"SteamSaleBot/storage"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeOldUser создает пользователя в старом формате: каталог по username и игры под старым хешем.
+func writeOldUser(t *testing.T, s *Storage, dirName string, chatID int, games ...storage.Game) {
+	t.Helper()
+	dir := filepath.Join(s.basePath, dirName)
+	if err := os.MkdirAll(filepath.Join(dir, "games"), defaultPerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.encodeSettings(filepath.Join(dir, "settings"), &storage.User{
+		UserSettings: storage.UserSettings{ChatId: chatID, Discounts: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i, g := range games {
+		f, err := os.Create(filepath.Join(dir, "games", "oldhash"+string(rune('a'+i))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = gob.NewEncoder(f).Encode(g)
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s := New(t.TempDir())
+	writeOldUser(t, s, "alice", 100, storage.Game{ID: "312520", Name: "Rain World"}, storage.Game{ID: "1145360", Name: "Hades"})
+	writeOldUser(t, s, "", 200, storage.Game{ID: "730", Name: "Counter-Strike 2"})
+	// история цен лежит рядом с пользователями и не должна трогаться
+	if err := s.AddPrice(&storage.Price{AppID: "730", Final: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id    int
+		name  string
+		games []string
+	}{
+		{100, "alice", []string{"312520", "1145360"}},
+		{200, "", []string{"730"}},
+	}
+	for _, tt := range tests {
+		u, err := s.Settings(tt.id)
+		if err != nil {
+			t.Fatalf("Settings(%d) error = %v", tt.id, err)
+		}
+		if u.ID != tt.id || u.UserName != tt.name || !u.UserSettings.Discounts {
+			t.Errorf("Settings(%d) = %+v, want id %d, name %q, discounts on", tt.id, u, tt.id, tt.name)
+		}
+
+		for _, gameID := range tt.games {
+			name, err := (&storage.User{ID: tt.id, Game: storage.Game{ID: gameID}}).Hash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, err := s.decodeGame(filepath.Join(s.userPath(tt.id), "games", name))
+			if err != nil {
+				t.Fatalf("game %s of user %d not re-hashed: %v", gameID, tt.id, err)
+			}
+			if g.ID != gameID {
+				t.Errorf("game file %s has id %s, want %s", name, g.ID, gameID)
+			}
+		}
+		games, err := s.CheckAllGame(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(games) != len(tt.games) {
+			t.Errorf("user %d has %d games, want %d", tt.id, len(games), len(tt.games))
+		}
+	}
+
+	for _, old := range []string{"alice", "settings", "games"} {
+		if _, err := os.Stat(filepath.Join(s.basePath, old)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("old %s still exists after migration, stat error = %v", old, err)
+		}
+	}
+	if _, err := s.Prices("730", 0); err != nil {
+		t.Errorf("price history lost after migration: %v", err)
+	}
+
+	// повторный запуск на уже перенесенных данных ничего не ломает
+	if err := s.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if games, err := s.CheckAllGame(100); err != nil || len(games) != 2 {
+		t.Errorf("after second Migrate user 100 has %d games, error %v", len(games), err)
+	}
+}
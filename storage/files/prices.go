@@ -0,0 +1,134 @@
+package files
+
+import (
+	"SteamSaleBot/lib/e"
+	"SteamSaleBot/storage"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// История цен хранится отдельно от пользователей: storage/db/prices/<app id>,
+// в каждом файле gob список всех записей по приложению от старых к новым.
+
+func (s Storage) AddPrice(p *storage.Price) (err error) {
+	defer func() { err = e.WrapIfErr("can't add price", err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fPath, err := s.pricePath(p.AppID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fPath), defaultPerm); err != nil {
+		return err
+	}
+
+	prices, err := s.decodePrices(fPath)
+	if err != nil && !errors.Is(err, storage.ErrNoPriceHistory) {
+		return err
+	}
+	prices = append(prices, p)
+
+	file, err := os.Create(fPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	return gob.NewEncoder(file).Encode(prices)
+}
+
+func (s Storage) Prices(appID string, limit int) (res []*storage.Price, err error) {
+	defer func() { err = e.WrapIfErr("can't get prices", err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fPath, err := s.pricePath(appID)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := s.decodePrices(fPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(prices) - 1; i >= 0 && (limit <= 0 || len(res) < limit); i-- {
+		res = append(res, prices[i])
+	}
+	return res, nil
+}
+
+func (s Storage) LowestPrice(appID string) (low *storage.Price, err error) {
+	defer func() { err = e.WrapIfErr("can't get lowest price", err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fPath, err := s.pricePath(appID)
+	if err != nil {
+		return nil, err
+	}
+	prices, err := s.decodePrices(fPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range prices {
+		if low == nil || p.Final < low.Final {
+			low = p
+		}
+	}
+	return low, nil
+}
+
+// PriceApps возвращает id приложений, по которым есть история цен.
+func (s Storage) PriceApps() (apps []string, err error) {
+	defer func() { err = e.WrapIfErr("can't list prices", err) }()
+
+	files, err := os.ReadDir(filepath.Join(s.basePath, pricesDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if _, err := strconv.Atoi(file.Name()); err == nil && !file.IsDir() {
+			apps = append(apps, file.Name())
+		}
+	}
+	return apps, nil
+}
+
+func (s Storage) decodePrices(filePath string) ([]*storage.Price, error) {
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, storage.ErrNoPriceHistory
+	}
+	if err != nil {
+		return nil, e.Warp("can't open prices", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var prices []*storage.Price
+	if err := gob.NewDecoder(f).Decode(&prices); err != nil {
+		return nil, e.Warp("can't decode prices", err)
+	}
+	if len(prices) == 0 {
+		return nil, storage.ErrNoPriceHistory
+	}
+	return prices, nil
+}
+
+func (s Storage) pricePath(appID string) (string, error) {
+	// id приходит от пользователя, не даем выйти за пределы каталога
+	if _, err := strconv.Atoi(appID); err != nil {
+		return "", storage.ErrNoPriceHistory
+	}
+	return filepath.Join(s.basePath, pricesDir, appID), nil
+}
@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"SteamSaleBot/lib/e"
+	"SteamSaleBot/storage"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const priceColumns = `app_id, name, initial, final, discount_percent, initial_formatted, final_formatted, created_at`
+
+func (s *Storage) AddPrice(p *storage.Price) error {
+	q := `INSERT INTO prices (` + priceColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := s.db.Exec(q, p.AppID, p.Name, p.Initial, p.Final, p.DiscountPercent,
+		p.InitialFormatted, p.FinalFormatted, p.Time.Unix())
+	if err != nil {
+		return e.Warp("can't add price", err)
+	}
+	return nil
+}
+
+func (s *Storage) Prices(appID string, limit int) (prices []*storage.Price, err error) {
+	defer func() { err = e.WrapIfErr("can't get prices", err) }()
+
+	if limit <= 0 {
+		limit = -1
+	}
+	q := `SELECT ` + priceColumns + ` FROM prices WHERE app_id = ? ORDER BY created_at DESC, rowid DESC LIMIT ?`
+	rows, err := s.db.Query(q, appID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		p, err := scanPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(prices) == 0 {
+		return nil, storage.ErrNoPriceHistory
+	}
+	return prices, nil
+}
+
+func (s *Storage) LowestPrice(appID string) (*storage.Price, error) {
+	q := `SELECT ` + priceColumns + ` FROM prices WHERE app_id = ? ORDER BY final, created_at LIMIT 1`
+
+	p, err := scanPrice(s.db.QueryRow(q, appID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, e.Warp("can't get lowest price", storage.ErrNoPriceHistory)
+	}
+	if err != nil {
+		return nil, e.Warp("can't get lowest price", err)
+	}
+	return p, nil
+}
+
+func scanPrice(row scanner) (*storage.Price, error) {
+	var (
+		p  storage.Price
+		ts int64
+	)
+	err := row.Scan(&p.AppID, &p.Name, &p.Initial, &p.Final, &p.DiscountPercent,
+		&p.InitialFormatted, &p.FinalFormatted, &ts)
+	if err != nil {
+		return nil, err
+	}
+	p.Time = time.Unix(ts, 0)
+	return &p, nil
+}
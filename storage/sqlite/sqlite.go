@@ -0,0 +1,314 @@
+package sqlite
+
+import (
+	"SteamSaleBot/lib/e"
+	"SteamSaleBot/storage"
+	"database/sql"
+	"os"
+
+	_ "modernc.org/sqlite"
+)
+
+type Storage struct {
+	db *sql.DB
+}
+
+func New(path string) (*Storage, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, e.Warp("can't open database", err)
+	}
+
+	// sqlite не любит конкурентную запись, уведомления пишут из нескольких горутин
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		return nil, e.Warp("can't connect to database", err)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Init создает таблицы. Внешних ключей нет: игру можно добавить до /start,
+// а пользователей бот не удаляет, только выключает через SetActive.
+func (s *Storage) Init() error {
+	q := `
+CREATE TABLE IF NOT EXISTS users (
+	id        INTEGER PRIMARY KEY,
+	user_name TEXT    NOT NULL DEFAULT '',
+	chat_id   INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS settings (
+	user_id      INTEGER PRIMARY KEY,
+	sales        INTEGER NOT NULL DEFAULT 1,
+	free_weekend INTEGER NOT NULL DEFAULT 1,
+	discounts    INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE IF NOT EXISTS games (
+	user_id INTEGER NOT NULL,
+	game_id TEXT    NOT NULL,
+	name    TEXT    NOT NULL,
+	price   TEXT    NOT NULL,
+	PRIMARY KEY (user_id, game_id)
+);
+CREATE TABLE IF NOT EXISTS prices (
+	app_id            TEXT    NOT NULL,
+	name              TEXT    NOT NULL,
+	initial           INTEGER NOT NULL,
+	final             INTEGER NOT NULL,
+	discount_percent  INTEGER NOT NULL,
+	initial_formatted TEXT    NOT NULL,
+	final_formatted   TEXT    NOT NULL,
+	created_at        INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS prices_app_id ON prices (app_id, created_at);`
+
+	if _, err := s.db.Exec(q); err != nil {
+		return e.Warp("can't create tables", err)
+	}
+
+	// колонки, добавленные после создания таблиц
+	columns := []struct{ table, column, def string }{
+		{"settings", "historical_low", "INTEGER NOT NULL DEFAULT 0"},
+		{"games", "target_price", "INTEGER NOT NULL DEFAULT 0"},
+		{"games", "target_discount", "INTEGER NOT NULL DEFAULT 0"},
+		{"games", "target_reached", "INTEGER NOT NULL DEFAULT 0"},
+		{"games", "final", "INTEGER NOT NULL DEFAULT 0"},
+		{"games", "currency", "TEXT NOT NULL DEFAULT ''"},
+		{"settings", "inactive", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, c := range columns {
+		if err := s.addColumn(c.table, c.column, c.def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) addColumn(table, column, def string) (err error) {
+	defer func() { err = e.WrapIfErr("can't add column "+column, err) }()
+
+	rows, err := s.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_ = rows.Close()
+
+	_, err = s.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + def)
+	return err
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+func (s *Storage) Save(u *storage.User) error {
+	q := `INSERT INTO games (user_id, ` + gameColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, game_id) DO UPDATE SET name = excluded.name, price = excluded.price,
+			final = excluded.final, currency = excluded.currency,
+			target_price = excluded.target_price, target_discount = excluded.target_discount,
+			target_reached = excluded.target_reached`
+
+	g := u.Game
+	_, err := s.db.Exec(q, u.ID, g.ID, g.Name, g.Price, g.Final, g.Currency, g.TargetPrice, g.TargetDiscount, g.TargetReached)
+	if err != nil {
+		return e.Warp("can't save game", err)
+	}
+	return nil
+}
+
+func (s *Storage) CheckAllGame(userID int) (games []*storage.Game, err error) {
+	defer func() { err = e.WrapIfErr("can't check games", err) }()
+
+	rows, err := s.db.Query(`SELECT `+gameColumns+` FROM games WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		g, err := scanGame(rows)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(games) == 0 {
+		return nil, storage.ErrNotSavedGame
+	}
+	return games, nil
+}
+
+func (s *Storage) Remove(u *storage.User) error {
+	res, err := s.db.Exec(`DELETE FROM games WHERE user_id = ? AND game_id = ?`, u.ID, u.Game.ID)
+	if err != nil {
+		return e.Warp("can't remove game", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return e.Warp("can't remove game", err)
+	}
+	if n == 0 {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+func (s *Storage) CreateSettings(u *storage.User) (err error) {
+	defer func() { err = e.WrapIfErr("can't create settings", err) }()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	q := `INSERT INTO users (id, user_name, chat_id) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET user_name = excluded.user_name, chat_id = excluded.chat_id`
+	if _, err := tx.Exec(q, u.ID, u.UserName, u.UserSettings.ChatId); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO settings (user_id) VALUES (?)`, u.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Storage) UpdSettings(userID int, change []string) (err error) {
+	defer func() { err = e.WrapIfErr("can't upd settings", err) }()
+
+	user, err := s.Settings(userID)
+	if err != nil {
+		return err
+	}
+	user.UserSettings.Toggle(change)
+
+	q := `UPDATE settings SET sales = ?, free_weekend = ?, discounts = ?, historical_low = ? WHERE user_id = ?`
+	set := user.UserSettings
+	if _, err := s.db.Exec(q, set.Sales, set.FreeWeekend, set.Discounts, set.HistoricalLow, userID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) SetActive(userID int, active bool) error {
+	if _, err := s.db.Exec(`UPDATE settings SET inactive = ? WHERE user_id = ?`, !active, userID); err != nil {
+		return e.Warp("can't set active", err)
+	}
+	return nil
+}
+
+func (s *Storage) Settings(userID int) (*storage.User, error) {
+	q := `SELECT ` + userColumns + ` FROM users u JOIN settings s ON s.user_id = u.id WHERE u.id = ?`
+
+	u, err := scanUser(s.db.QueryRow(q, userID))
+	if err != nil {
+		return &storage.User{}, e.Warp("can't get settings", err)
+	}
+	return u, nil
+}
+
+func (s *Storage) Users() (users map[*storage.User][]*storage.Game, err error) {
+	defer func() { err = e.WrapIfErr("can't get users", err) }()
+
+	q := `SELECT ` + userColumns + ` FROM users u JOIN settings s ON s.user_id = u.id`
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	byID := make(map[int]*storage.User)
+	users = make(map[*storage.User][]*storage.Game)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[u.ID] = u
+		users[u] = make([]*storage.Game, 0)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	gameRows, err := s.db.Query(`SELECT user_id, ` + gameColumns + ` FROM games`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = gameRows.Close() }()
+
+	for gameRows.Next() {
+		var userID int
+		g, err := scanGame(gameRows, &userID)
+		if err != nil {
+			return nil, err
+		}
+		u, ok := byID[userID]
+		if !ok {
+			continue
+		}
+		users[u] = append(users[u], g)
+	}
+	if err := gameRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+const gameColumns = `game_id, name, price, final, currency, target_price, target_discount, target_reached`
+
+const userColumns = `u.id, u.user_name, u.chat_id, s.sales, s.free_weekend, s.discounts, s.historical_low, s.inactive`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (*storage.User, error) {
+	var u storage.User
+	err := row.Scan(
+		&u.ID,
+		&u.UserName,
+		&u.UserSettings.ChatId,
+		&u.UserSettings.Sales,
+		&u.UserSettings.FreeWeekend,
+		&u.UserSettings.Discounts,
+		&u.UserSettings.HistoricalLow,
+		&u.UserSettings.Inactive,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func scanGame(row scanner, prefix ...any) (*storage.Game, error) {
+	var g storage.Game
+	dest := append(prefix, &g.ID, &g.Name, &g.Price, &g.Final, &g.Currency, &g.TargetPrice, &g.TargetDiscount, &g.TargetReached)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
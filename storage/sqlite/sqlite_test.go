@@ -0,0 +1,209 @@
+package sqlite
+
+import (
+	"SteamSaleBot/storage"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T) (*Storage, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bot.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func TestSaveCheckAllGame(t *testing.T) {
+	s, _ := newTestStorage(t)
+
+	if _, err := s.CheckAllGame(1); !errors.Is(err, storage.ErrNotSavedGame) {
+		t.Fatalf("CheckAllGame without games error = %v, want ErrNotSavedGame", err)
+	}
+
+	game := storage.Game{
+		ID: "312520", Name: "Rain World", Price: "300 руб.", Final: 30000, Currency: "RUB",
+		TargetPrice: 25000, TargetDiscount: 50, TargetReached: true,
+	}
+	if err := s.Save(&storage.User{ID: 1, Game: game}); err != nil {
+		t.Fatal(err)
+	}
+	// повторное сохранение обновляет игру, а не добавляет вторую
+	game.Final = 20000
+	if err := s.Save(&storage.User{ID: 1, Game: game}); err != nil {
+		t.Fatal(err)
+	}
+
+	games, err := s.CheckAllGame(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || !reflect.DeepEqual(*games[0], game) {
+		t.Fatalf("CheckAllGame() = %+v, want [%+v]", games, game)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	s, _ := newTestStorage(t)
+	u := &storage.User{ID: 1, Game: storage.Game{ID: "312520", Name: "Rain World"}}
+	if err := s.Save(u); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Remove(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(u); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Remove of missing game error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	s, _ := newTestStorage(t)
+	if err := s.CreateSettings(&storage.User{ID: 1, UserName: "alice", UserSettings: storage.UserSettings{ChatId: 10}}); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := s.Settings(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := storage.UserSettings{ChatId: 10, Sales: true, FreeWeekend: true, Discounts: true}
+	if u.UserName != "alice" || u.UserSettings != want {
+		t.Fatalf("Settings() = %q %+v, want alice %+v", u.UserName, u.UserSettings, want)
+	}
+
+	if err := s.UpdSettings(1, []string{"1", "4"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetActive(1, false); err != nil {
+		t.Fatal(err)
+	}
+	u, err = s.Settings(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Sales, want.HistoricalLow, want.Inactive = false, true, true
+	if u.UserSettings != want {
+		t.Fatalf("Settings() after toggle = %+v, want %+v", u.UserSettings, want)
+	}
+
+	// повторный /start меняет имя и чат, но не сбрасывает настройки
+	if err := s.CreateSettings(&storage.User{ID: 1, UserName: "bob", UserSettings: storage.UserSettings{ChatId: 20}}); err != nil {
+		t.Fatal(err)
+	}
+	u, err = s.Settings(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.ChatId = 20
+	if u.UserName != "bob" || u.UserSettings != want {
+		t.Fatalf("Settings() after second start = %q %+v, want bob %+v", u.UserName, u.UserSettings, want)
+	}
+
+	if _, err := s.Settings(2); err == nil {
+		t.Fatal("Settings() of unknown user returned no error")
+	}
+}
+
+func TestUsers(t *testing.T) {
+	s, _ := newTestStorage(t)
+	for _, id := range []int{1, 2} {
+		if err := s.CreateSettings(&storage.User{ID: id, UserSettings: storage.UserSettings{ChatId: id}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, g := range []string{"10", "20"} {
+		if err := s.Save(&storage.User{ID: 1, Game: storage.Game{ID: g}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// игра пользователя без /start в Users не попадает
+	if err := s.Save(&storage.User{ID: 3, Game: storage.Game{ID: "30"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := s.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[int]int)
+	for u, games := range users {
+		got[u.ID] = len(games)
+	}
+	if want := map[int]int{1: 2, 2: 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Users() games per user = %v, want %v", got, want)
+	}
+}
+
+func TestPrices(t *testing.T) {
+	s, _ := newTestStorage(t)
+
+	if _, err := s.Prices("312520", 0); !errors.Is(err, storage.ErrNoPriceHistory) {
+		t.Fatalf("Prices() on empty history error = %v, want ErrNoPriceHistory", err)
+	}
+	if _, err := s.LowestPrice("312520"); !errors.Is(err, storage.ErrNoPriceHistory) {
+		t.Fatalf("LowestPrice() on empty history error = %v, want ErrNoPriceHistory", err)
+	}
+
+	start := time.Unix(1700000000, 0)
+	for i, final := range []int{50000, 30000, 40000} {
+		p := &storage.Price{AppID: "312520", Initial: 50000, Final: final, Time: start.Add(time.Duration(i) * time.Hour)}
+		if err := s.AddPrice(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prices, err := s.Prices("312520", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 2 || prices[0].Final != 40000 || prices[1].Final != 30000 {
+		t.Fatalf("Prices(2) = %+v, want newest first 40000, 30000", prices)
+	}
+	low, err := s.LowestPrice("312520")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low.Final != 30000 || !low.Time.Equal(start.Add(time.Hour)) {
+		t.Fatalf("LowestPrice() = %+v, want 30000 at %v", low, start.Add(time.Hour))
+	}
+}
+
+func TestInitTwice(t *testing.T) {
+	s, path := newTestStorage(t)
+	game := storage.Game{ID: "312520", Name: "Rain World", TargetPrice: 25000}
+	if err := s.Save(&storage.User{ID: 1, Game: game}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Init(); err != nil {
+		t.Fatalf("second Init() error = %v", err)
+	}
+	_ = s.Close()
+
+	reopened, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reopened.Close() }()
+	if err := reopened.Init(); err != nil {
+		t.Fatalf("Init() on existing database error = %v", err)
+	}
+	games, err := reopened.CheckAllGame(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 || !reflect.DeepEqual(*games[0], game) {
+		t.Fatalf("CheckAllGame() after reopen = %+v, want [%+v]", games, game)
+	}
+}
@@ -6,35 +6,71 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"time"
 )
 
-var ErrNotSavedGame = errors.New("no save Game")
+var (
+	ErrNotSavedGame   = errors.New("no save Game")
+	ErrNoPriceHistory = errors.New("no price history")
+)
 
 type Storage interface {
 	Save(g *User) error
-	CheckAllGame(userName string) ([]*Game, error)
+	CheckAllGame(userID int) ([]*Game, error)
 	Remove(g *User) error
 	CreateSettings(g *User) error
-	UpdSettings(userName string, settings []string) (err error)
-	Settings(userName string) (*User, error)
+	UpdSettings(userID int, settings []string) (err error)
+	Settings(userID int) (*User, error)
+	SetActive(userID int, active bool) error
 	Users() (map[*User][]*Game, error)
+	PriceHistory
+}
+
+// PriceHistory общая для всех пользователей история цен по id приложения в Steam.
+type PriceHistory interface {
+	AddPrice(p *Price) error
+	Prices(appID string, limit int) ([]*Price, error) // от новых к старым
+	LowestPrice(appID string) (*Price, error)
 }
 
 type User struct {
-	UserName     string
+	ID           int    // id пользователя в Telegram
+	UserName     string // только для отображения, может меняться или отсутствовать
 	UserSettings UserSettings
 	Game         Game
 }
 type UserSettings struct {
-	ChatId      int
-	Discounts   bool
-	FreeWeekend bool
-	Sales       bool
+	ChatId        int
+	Discounts     bool
+	FreeWeekend   bool
+	Sales         bool
+	HistoricalLow bool // уведомлять об историческом минимуме цены
+	Inactive      bool // пользователь заблокировал бота, уведомления не шлем до /start
 }
 type Game struct {
-	Name  string
-	ID    string
-	Price string
+	Name     string
+	ID       string
+	Price    string // цена в формате Steam, только для отображения
+	Final    int    // последняя цена в копейках, по ней сравниваются скидки
+	Currency string
+
+	// Условия уведомления, заданные пользователем при /add. Цена в копейках, скидка в процентах.
+	TargetPrice    int
+	TargetDiscount int
+	TargetReached  bool // уведомление уже отправлено, ждем пока условие перестанет выполняться
+}
+
+type Price struct {
+	AppID            string
+	Name             string
+	Initial          int // в копейках, как отдает Steam
+	Final            int
+	DiscountPercent  int
+	InitialFormatted string
+	FinalFormatted   string
+	Time             time.Time
 }
 
 func (u *User) Hash() (string, error) {
@@ -44,9 +80,40 @@ func (u *User) Hash() (string, error) {
 		return "", e.Warp("can't calculate hash", err)
 	}
 
-	if _, err := io.WriteString(h, u.UserName); err != nil {
+	if _, err := io.WriteString(h, strconv.Itoa(u.ID)); err != nil {
 		return "", e.Warp("can't calculate hash", err)
 	}
 
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
+
+func (g *Game) HasTarget() bool {
+	return g.TargetPrice > 0 || g.TargetDiscount > 0
+}
+
+// TargetMet проверяет цену и скидку из Steam по условиям игры, заданные условия должны выполняться все.
+func (g *Game) TargetMet(final, discountPercent int) bool {
+	if g.TargetPrice > 0 && final > g.TargetPrice {
+		return false
+	}
+	if g.TargetDiscount > 0 && discountPercent < g.TargetDiscount {
+		return false
+	}
+	return true
+}
+
+// Toggle переключает настройки по их номерам из меню /settings.
+func (s *UserSettings) Toggle(change []string) {
+	for _, i := range change {
+		switch strings.TrimSpace(i) {
+		case "1":
+			s.Sales = !s.Sales
+		case "2":
+			s.FreeWeekend = !s.FreeWeekend
+		case "3":
+			s.Discounts = !s.Discounts
+		case "4":
+			s.HistoricalLow = !s.HistoricalLow
+		}
+	}
+}